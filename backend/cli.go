@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"shopping-cart/utils"
+	"strings"
+)
+
+const usage = `Usage:
+  shopping-cart                                  start the server
+  shopping-cart import [-format csv|jsonl] [-dry-run] FILE
+  shopping-cart export [-format csv|jsonl] [-o FILE]
+`
+
+// runCommand runs a command-line subcommand and returns the exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:])
+	case "export":
+		return runExport(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "file format, csv or jsonl (default from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate the file without importing it")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = utils.CatalogFormatCSV
+		if strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".ndjson") {
+			*format = utils.CatalogFormatJSONL
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open catalog:", err)
+		return 1
+	}
+	defer file.Close()
+
+	rows, parseErrors, err := utils.ParseCatalog(file, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read catalog:", err)
+		return 1
+	}
+
+	result, err := utils.ImportCatalog(utils.DB, rows, parseErrors, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to import catalog:", err)
+		return 1
+	}
+
+	for _, rowError := range result.Errors {
+		fmt.Fprintf(os.Stderr, "row %d %s: %s\n", rowError.Row, rowError.SKU, strings.Join(rowError.Errors, "; "))
+	}
+	if len(result.Errors) > 0 {
+		fmt.Fprintf(os.Stderr, "%d invalid rows, nothing was imported\n", len(result.Errors))
+		return 1
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d rows: %d created, %d updated\n", verb, result.Rows, result.Created, result.Updated)
+	return 0
+}
+
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", utils.CatalogFormatCSV, "file format, csv or jsonl")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create output file:", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := utils.ExportCatalog(utils.DB, w, *format); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to export catalog:", err)
+		return 1
+	}
+	return 0
+}
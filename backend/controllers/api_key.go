@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	UserID    uint       `json:"user_id"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse describes a key without its secret
+type APIKeyResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	UserID      uint       `json:"user_id"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	CreatedByID uint       `json:"created_by_id"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(key models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		UserID:      key.UserID,
+		Prefix:      key.Prefix,
+		Scopes:      key.ScopeList(),
+		CreatedByID: key.CreatedByID,
+		LastUsedAt:  key.LastUsedAt,
+		ExpiresAt:   key.ExpiresAt,
+		RevokedAt:   key.RevokedAt,
+		CreatedAt:   key.CreatedAt,
+	}
+}
+
+func CreateAPIKey(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate scopes
+	valid := map[string]bool{}
+	for _, scope := range models.APIKeyScopes {
+		valid[scope] = true
+	}
+	for _, scope := range req.Scopes {
+		if !valid[scope] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope})
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	// Keys act as the admin creating them unless another user is given
+	userID := req.UserID
+	if userID == 0 {
+		userID = adminID
+	}
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	secret, _, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	rawKey := "sk_" + secret
+
+	apiKey := models.APIKey{
+		Name:        req.Name,
+		UserID:      user.ID,
+		Prefix:      rawKey[:11],
+		KeyHash:     utils.HashToken(rawKey),
+		Scopes:      strings.Join(req.Scopes, ","),
+		CreatedByID: adminID,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if err := utils.DB.Create(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created successfully",
+		"key":     rawKey,
+		"api_key": newAPIKeyResponse(apiKey),
+	})
+}
+
+func ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := utils.DB.Order("id").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = newAPIKeyResponse(key)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": response})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := utils.DB.First(&apiKey, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := utils.DB.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -1,22 +1,39 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"shopping-cart/models"
 	"shopping-cart/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AddToCartRequest struct {
-	ItemID   uint `json:"item_id" binding:"required"`
-	Quantity int  `json:"quantity" binding:"required,min=1"`
+	ItemID    uint  `json:"item_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
 }
 
+// RemoveFromCartRequest removes every line of the item unless a variant
+// is given
 type RemoveFromCartRequest struct {
-	ItemID uint `json:"item_id" binding:"required"`
+	ItemID    uint  `json:"item_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
 }
 
+var (
+	errCartVersionMismatch = errors.New("cart version mismatch")
+	errCartCreate          = errors.New("failed to create cart")
+	errCartItemWrite       = errors.New("failed to write cart item")
+)
+
+// maxCartAttempts bounds retries of an add that lost a race to create the
+// cart or the cart line
+const maxCartAttempts = 3
+
 func AddToCart(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	var req AddToCartRequest
@@ -25,52 +42,157 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
+	expectedVersion, hasIfMatch, err := utils.ParseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
+	cart, ok := addToCart(c, userID, req, expectedVersion, hasIfMatch)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", utils.FormatETag(cart.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Item added to cart successfully"})
+}
+
+// addToCart puts the requested quantity into the user's active cart,
+// creating the cart if needed. On failure the error response has been
+// written and false is returned.
+func addToCart(c *gin.Context, userID uint, req AddToCartRequest, expectedVersion uint, hasIfMatch bool) (models.Cart, bool) {
+	var cart models.Cart
+
 	// Check if item exists
 	var item models.Item
 	if err := utils.DB.First(&item, req.ItemID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
-		return
+		return cart, false
 	}
 
-	// Get user's active cart or create new one
-	var cart models.Cart
-	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error; err != nil {
-		// Create new cart
-		cart = models.Cart{
-			UserID: userID,
-			Name:   "Shopping Cart",
-			Status: "active",
+	// Only published items can be bought
+	switch item.EffectiveStatus(time.Now()) {
+	case models.ItemDraft:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return cart, false
+	case models.ItemArchived:
+		c.JSON(http.StatusConflict, gin.H{"error": "Item is no longer available"})
+		return cart, false
+	}
+
+	variant, err := resolveCartVariant(utils.DB, item, req.VariantID)
+	switch {
+	case errors.Is(err, errVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "variant_id is required for this item"})
+		return cart, false
+	case errors.Is(err, errVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return cart, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+		return cart, false
+	}
+
+	price := item.Price
+	if variant != nil {
+		price = variant.EffectivePrice(item)
+	}
+
+	add := func(tx *gorm.DB) error {
+		// Get user's active cart or create new one
+		cart = models.Cart{}
+		if err := tx.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error; err != nil {
+			if hasIfMatch {
+				return errCartVersionMismatch
+			}
+
+			// Create new cart
+			cart = models.Cart{
+				UserID: userID,
+				Name:   "Shopping Cart",
+				Status: "active",
+			}
+			if err := tx.Create(&cart).Error; err != nil {
+				if utils.IsUniqueViolation(err) {
+					return err
+				}
+				return errCartCreate
+			}
+
+			// Update user's cart_id
+			tx.Model(&models.User{}).Where("id = ?", userID).Update("cart_id", cart.ID)
+		} else if err := bumpCartVersion(tx, &cart, expectedVersion, hasIfMatch); err != nil {
+			return err
+		}
+
+		// Variants are limited by their stock, counting what is already in the cart
+		if variant != nil {
+			var inCart int64
+			whereVariant(tx.Model(&models.CartItem{}).Where("cart_id = ?", cart.ID), req.VariantID).
+				Select("COALESCE(SUM(quantity), 0)").Scan(&inCart)
+			if err := tx.Select("stock").First(variant, variant.ID).Error; err != nil {
+				return err
+			}
+			if int(inCart)+req.Quantity > variant.Stock {
+				return errOutOfStock
+			}
 		}
-		if err := utils.DB.Create(&cart).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart"})
-			return
+
+		// Adding to a cart without an open session starts one, for the metrics
+		if err := utils.StartCartSession(tx, cart, time.Now()); err != nil {
+			return errCartItemWrite
+		}
+
+		// Increment the quantity in place so concurrent adds are not lost
+		result := whereVariant(tx.Model(&models.CartItem{}).Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID), req.VariantID).
+			Update("quantity", gorm.Expr("quantity + ?", req.Quantity))
+		if result.Error != nil {
+			return errCartItemWrite
+		}
+		if result.RowsAffected > 0 {
+			return nil
 		}
-		
-		// Update user's cart_id
-		utils.DB.Model(&models.User{}).Where("id = ?", userID).Update("cart_id", cart.ID)
-	}
-
-	// Check if item already in cart
-	var existingCartItem models.CartItem
-	if err := utils.DB.Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID).First(&existingCartItem).Error; err == nil {
-		// Update quantity
-		existingCartItem.Quantity += req.Quantity
-		utils.DB.Save(&existingCartItem)
-	} else {
+
 		// Add new item to cart
 		cartItem := models.CartItem{
-			CartID:   cart.ID,
-			ItemID:   req.ItemID,
-			Quantity: req.Quantity,
-			Price:    item.Price,
+			CartID:    cart.ID,
+			ItemID:    req.ItemID,
+			VariantID: req.VariantID,
+			Quantity:  req.Quantity,
+			Price:     price,
+		}
+		if err := tx.Create(&cartItem).Error; err != nil {
+			if utils.IsUniqueViolation(err) {
+				return err
+			}
+			return errCartItemWrite
 		}
-		if err := utils.DB.Create(&cartItem).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
-			return
+		return nil
+	}
+	// A parallel first add can create the cart or the line before us. The
+	// unique indexes reject our insert, and the retry updates theirs.
+	for attempt := 1; ; attempt++ {
+		err = utils.DB.Transaction(add)
+		if !utils.IsUniqueViolation(err) || attempt == maxCartAttempts {
+			break
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Item added to cart successfully"})
+	switch {
+	case errors.Is(err, errCartVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Cart has been modified, reload and retry"})
+		return cart, false
+	case errors.Is(err, errOutOfStock):
+		c.JSON(http.StatusConflict, gin.H{"error": "Not enough stock for this variant"})
+		return cart, false
+	case errors.Is(err, errCartCreate):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart"})
+		return cart, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+		return cart, false
+	}
+	return cart, true
 }
 
 func RemoveFromCart(c *gin.Context) {
@@ -81,6 +203,12 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 
+	expectedVersion, hasIfMatch, err := utils.ParseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
 	// Get user's active cart
 	var cart models.Cart
 	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error; err != nil {
@@ -88,30 +216,76 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 
-	// Remove item from cart
-	if err := utils.DB.Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID).Delete(&models.CartItem{}).Error; err != nil {
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := bumpCartVersion(tx, &cart, expectedVersion, hasIfMatch); err != nil {
+			return err
+		}
+
+		// Remove item from cart
+		query := tx.Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID)
+		if req.VariantID != nil {
+			query = query.Where("variant_id = ?", *req.VariantID)
+		}
+		return query.Delete(&models.CartItem{}).Error
+	})
+
+	if errors.Is(err, errCartVersionMismatch) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Cart has been modified, reload and retry"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from cart"})
 		return
 	}
 
+	c.Header("ETag", utils.FormatETag(cart.Version))
 	c.JSON(http.StatusOK, gin.H{"message": "Item removed from cart successfully"})
 }
 
+// whereVariant narrows a cart line query to the variant, or to lines
+// without one
+func whereVariant(query *gorm.DB, variantID *uint) *gorm.DB {
+	if variantID == nil {
+		return query.Where("variant_id IS NULL")
+	}
+	return query.Where("variant_id = ?", *variantID)
+}
+
+// bumpCartVersion increments the cart version. When the client sent If-Match
+// the increment only applies if the stored version still matches.
+func bumpCartVersion(tx *gorm.DB, cart *models.Cart, expectedVersion uint, hasIfMatch bool) error {
+	query := tx.Model(&models.Cart{}).Where("id = ?", cart.ID)
+	if hasIfMatch {
+		query = query.Where("version = ?", expectedVersion)
+	}
+
+	result := query.Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errCartVersionMismatch
+	}
+
+	return tx.Select("version").First(cart, cart.ID).Error
+}
+
 func GetCart(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
 	var cart models.Cart
-	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").Preload("Items.Item").First(&cart).Error; err != nil {
+	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").Preload("Items.Item").Preload("Items.Variant.Options").First(&cart).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
 		return
 	}
 
+	c.Header("ETag", utils.FormatETag(cart.Version))
 	c.JSON(http.StatusOK, gin.H{"cart": cart})
 }
 
 func ListCarts(c *gin.Context) {
 	var carts []models.Cart
-	if err := utils.DB.Preload("User").Preload("Items.Item").Find(&carts).Error; err != nil {
+	if err := utils.DB.Preload("User").Preload("Items.Item").Preload("Items.Variant.Options").Find(&carts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carts"})
 		return
 	}
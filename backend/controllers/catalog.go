@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogContentTypes maps export formats to their response content type
+var catalogContentTypes = map[string]string{
+	utils.CatalogFormatCSV:   "text/csv; charset=utf-8",
+	utils.CatalogFormatJSONL: "application/x-ndjson",
+}
+
+func ImportCatalog(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	maxBytes := int64(utils.GetEnvInt("CATALOG_IMPORT_MAX_MB", 20)) << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+
+	// Accept a multipart upload in the file field or the file as the body
+	var body io.Reader = c.Request.Body
+	filename := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A catalog file is required in the file field"})
+			return
+		}
+		defer file.Close()
+		body = file
+		filename = header.Filename
+	}
+
+	format := catalogFormat(c.Query("format"), filename, c.ContentType())
+	rows, parseErrors, err := utils.ParseCatalog(body, format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Catalog file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := utils.ImportCatalog(utils.DB, rows, parseErrors, dryRun)
+	if utils.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A SKU in the catalog was taken by another item, retry the import"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import catalog"})
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Catalog has invalid rows, nothing was imported",
+			"result": result,
+		})
+		return
+	}
+
+	message := "Catalog imported successfully"
+	if dryRun {
+		message = "Catalog is valid, nothing was imported"
+	} else if err := utils.BuildSearchIndex(utils.DB); err != nil {
+		log.Println("Failed to rebuild search index:", err)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"result":  result,
+	})
+}
+
+func ExportCatalog(c *gin.Context) {
+	format := c.DefaultQuery("format", utils.CatalogFormatCSV)
+	contentType, ok := catalogContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrUnknownCatalogFormat.Error()})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="catalog.`+format+`"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged
+	if err := utils.ExportCatalog(utils.DB, c.Writer, format); err != nil {
+		log.Println("Failed to export catalog:", err)
+	}
+}
+
+// catalogFormat picks the import format from the format query parameter,
+// then the file extension, then the content type, defaulting to CSV
+func catalogFormat(query, filename, contentType string) string {
+	if query != "" {
+		return query
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		return utils.CatalogFormatJSONL
+	case ".csv":
+		return utils.CatalogFormatCSV
+	}
+	if contentType == "application/x-ndjson" || contentType == "application/jsonl" {
+		return utils.CatalogFormatJSONL
+	}
+	return utils.CatalogFormatCSV
+}
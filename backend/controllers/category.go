@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errCategoryNotFound = errors.New("category not found")
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+	Position int    `json:"position"`
+}
+
+// UpdateCategoryRequest changes only the fields that are present. A
+// parent_id of 0 moves the category to the top level.
+type UpdateCategoryRequest struct {
+	Name     *string `json:"name"`
+	Slug     *string `json:"slug"`
+	ParentID *uint   `json:"parent_id"`
+	Position *int    `json:"position"`
+}
+
+// CategoryNode is a category with its subcategories and item counts.
+// TotalItemCount includes items in all descendants.
+type CategoryNode struct {
+	ID             uint            `json:"id"`
+	Name           string          `json:"name"`
+	Slug           string          `json:"slug"`
+	ParentID       *uint           `json:"parent_id"`
+	Position       int             `json:"position"`
+	ItemCount      int64           `json:"item_count"`
+	TotalItemCount int64           `json:"total_item_count"`
+	Children       []*CategoryNode `json:"children"`
+}
+
+func ListCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := utils.DB.Order("position, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	var counts []categoryCount
+	if err := utils.DB.Model(&models.Item{}).Scopes(utils.PublishedItems(time.Now())).
+		Select("category_id, COUNT(*) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": buildCategoryTree(categories, counts)})
+}
+
+type categoryCount struct {
+	CategoryID uint
+	Count      int64
+}
+
+// buildCategoryTree nests categories under their parents, keeping the
+// position order within each level
+func buildCategoryTree(categories []models.Category, counts []categoryCount) []*CategoryNode {
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			ID:       category.ID,
+			Name:     category.Name,
+			Slug:     category.Slug,
+			ParentID: category.ParentID,
+			Position: category.Position,
+			Children: []*CategoryNode{},
+		}
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.CategoryID]; ok {
+			node.ItemCount = count.Count
+		}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var total func(node *CategoryNode) int64
+	total = func(node *CategoryNode) int64 {
+		node.TotalItemCount = node.ItemCount
+		for _, child := range node.Children {
+			node.TotalItemCount += total(child)
+		}
+		return node.TotalItemCount
+	}
+	for _, root := range roots {
+		total(root)
+	}
+	return roots
+}
+
+func GetCategory(c *gin.Context) {
+	category, ok := findCategoryParam(c)
+	if !ok {
+		return
+	}
+
+	var children []models.Category
+	utils.DB.Where("parent_id = ?", category.ID).Order("position, name").Find(&children)
+
+	c.JSON(http.StatusOK, gin.H{"category": category, "children": children})
+}
+
+func CreateCategory(c *gin.Context) {
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slug := utils.Slugify(req.Slug)
+	if slug == "" {
+		slug = utils.Slugify(req.Name)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name must contain letters or digits"})
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.Category
+		if err := utils.DB.First(&parent, *req.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+			return
+		}
+	}
+
+	if slugTaken(slug, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category slug already exists"})
+		return
+	}
+
+	category := models.Category{
+		Name:     strings.TrimSpace(req.Name),
+		Slug:     slug,
+		ParentID: req.ParentID,
+		Position: req.Position,
+	}
+	if err := utils.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Category created successfully",
+		"category": category,
+	})
+}
+
+func UpdateCategory(c *gin.Context) {
+	category, ok := findCategoryParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name cannot be empty"})
+			return
+		}
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Slug != nil {
+		slug := utils.Slugify(*req.Slug)
+		if slug == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category slug"})
+			return
+		}
+		if slugTaken(slug, category.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Category slug already exists"})
+			return
+		}
+		updates["slug"] = slug
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			updates["parent_id"] = nil
+		} else {
+			// A category cannot move below itself or one of its descendants
+			cycle, err := isDescendant(*req.ParentID, category.ID)
+			if err == errCategoryNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+				return
+			}
+			if cycle {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Category cannot be moved below itself"})
+				return
+			}
+			updates["parent_id"] = *req.ParentID
+		}
+	}
+	if req.Position != nil {
+		updates["position"] = *req.Position
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&category).Updates(updates).Error; err != nil {
+			return err
+		}
+		// Keep the denormalized name on items in sync
+		if name, ok := updates["name"]; ok {
+			return tx.Model(&models.Item{}).Where("category_id = ?", category.ID).Update("category", name).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	// Items are indexed with their category name
+	if _, ok := updates["name"]; ok {
+		var itemIDs []uint
+		utils.DB.Model(&models.Item{}).Where("category_id = ?", category.ID).Pluck("id", &itemIDs)
+		reindexItems(itemIDs...)
+	}
+
+	utils.DB.First(&category, category.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Category updated successfully",
+		"category": category,
+	})
+}
+
+func DeleteCategory(c *gin.Context) {
+	category, ok := findCategoryParam(c)
+	if !ok {
+		return
+	}
+
+	var children, items int64
+	utils.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children)
+	utils.DB.Model(&models.Item{}).Where("category_id = ?", category.ID).Count(&items)
+	if children > 0 || items > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category still has subcategories or items"})
+		return
+	}
+
+	if err := utils.DB.Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// findCategoryParam loads the category named by the :id parameter, writing
+// the error response itself when it cannot
+func findCategoryParam(c *gin.Context) (models.Category, bool) {
+	var category models.Category
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return category, false
+	}
+
+	if err := utils.DB.First(&category, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return category, false
+	}
+	return category, true
+}
+
+func slugTaken(slug string, exceptID uint) bool {
+	var count int64
+	utils.DB.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, exceptID).Count(&count)
+	return count > 0
+}
+
+// isDescendant reports whether candidate is ancestor itself or lies below it
+func isDescendant(candidate, ancestor uint) (bool, error) {
+	seen := map[uint]bool{}
+	id := &candidate
+	for id != nil && !seen[*id] {
+		if *id == ancestor {
+			return true, nil
+		}
+		seen[*id] = true
+
+		var category models.Category
+		if err := utils.DB.First(&category, *id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return false, errCategoryNotFound
+			}
+			return false, err
+		}
+		id = category.ParentID
+	}
+	return false, nil
+}
+
+// resolveItemCategory picks the category for an item from an explicit ID or,
+// for older clients, from the free-text category name. Unknown names create
+// a new top-level category; unknown IDs are rejected.
+func resolveItemCategory(categoryID *uint, name string) (*models.Category, error) {
+	if categoryID != nil {
+		var category models.Category
+		if err := utils.DB.First(&category, *categoryID).Error; err != nil {
+			return nil, errCategoryNotFound
+		}
+		return &category, nil
+	}
+	if utils.Slugify(name) == "" {
+		return nil, nil
+	}
+	category, err := utils.EnsureCategory(utils.DB, name)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetOrderInvoice renders the invoice of a paid order as HTML or, with
+// format=pdf, as a PDF download
+func GetOrderInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or pdf"})
+		return
+	}
+
+	// Other users' orders are reported as missing
+	query := utils.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+	if c.GetString("user_role") != models.RoleAdmin {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	}
+	var order models.Order
+	if err := query.First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	if !utils.IsInvoiceStatus(order.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has not been paid"})
+		return
+	}
+
+	var invoice models.Invoice
+	if err := utils.DB.Where("order_id = ?", order.ID).First(&invoice).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	view := utils.NewInvoiceView(invoice, order)
+	var buf bytes.Buffer
+	if format == "pdf" {
+		if err := utils.RenderInvoicePDF(&buf, view); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="invoice-%s.pdf"`, invoice.Number))
+		c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+		return
+	}
+
+	if err := utils.RenderInvoiceHTML(&buf, view); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -1,23 +1,72 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"shopping-cart/models"
 	"shopping-cart/utils"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+var errItemVersionMismatch = errors.New("item version mismatch")
+
 type CreateItemRequest struct {
+	SKU         string  `json:"sku"`
 	Name        string  `json:"name" binding:"required"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price" binding:"required"`
 	Category    string  `json:"category"`
-	Rating      float64 `json:"rating"`
-	Reviews     int     `json:"reviews"`
+	CategoryID  *uint   `json:"category_id"`
 	Image       string  `json:"image"`
 	InStock     bool    `json:"in_stock"`
+	// Status defaults to active; publish_at and unpublish_at schedule changes
+	Status      string     `json:"status" binding:"omitempty,oneof=draft active archived"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+type UpdateItemRequest struct {
+	SKU         *string    `json:"sku"`
+	Name        *string    `json:"name"`
+	Description *string    `json:"description"`
+	Price       *float64   `json:"price"`
+	Category    *string    `json:"category"`
+	CategoryID  *uint      `json:"category_id"`
+	Image       *string    `json:"image"`
+	InStock     *bool      `json:"in_stock"`
+	Status      *string    `json:"status" binding:"omitempty,oneof=draft active archived"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	// ClearPublishAt and ClearUnpublishAt remove a scheduled change
+	ClearPublishAt   bool `json:"clear_publish_at"`
+	ClearUnpublishAt bool `json:"clear_unpublish_at"`
+}
+
+// canSeeUnpublished reports whether the caller may see draft and archived
+// items: admins, or API keys of admins with the admin scope
+func canSeeUnpublished(c *gin.Context) bool {
+	if c.GetString("user_role") != models.RoleAdmin {
+		return false
+	}
+	scopes, ok := c.Get("api_key_scopes")
+	if !ok {
+		return true
+	}
+	for _, scope := range scopes.([]string) {
+		if scope == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// validSchedule checks that an item is not unpublished before it is published
+func validSchedule(publishAt, unpublishAt *time.Time) bool {
+	return publishAt == nil || unpublishAt == nil || unpublishAt.After(*publishAt)
 }
 
 func CreateItem(c *gin.Context) {
@@ -27,22 +76,61 @@ func CreateItem(c *gin.Context) {
 		return
 	}
 
+	if !validSchedule(req.PublishAt, req.UnpublishAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be after publish_at"})
+		return
+	}
+
+	if req.SKU != "" && utils.ItemSKUTaken(utils.DB, req.SKU, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+		return
+	}
+
+	category, err := resolveItemCategory(req.CategoryID, req.Category)
+	if err == errCategoryNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve category"})
+		return
+	}
+
 	item := models.Item{
+		SKU:         req.SKU,
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
 		Category:    req.Category,
-		Rating:      req.Rating,
-		Reviews:     req.Reviews,
 		Image:       req.Image,
 		InStock:     req.InStock,
+		Status:      req.Status,
+		PublishAt:   req.PublishAt,
+		UnpublishAt: req.UnpublishAt,
+	}
+	if category != nil {
+		item.Category = category.Name
+		item.CategoryID = &category.ID
 	}
 
-	if err := utils.DB.Create(&item).Error; err != nil {
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		userID := c.GetUint("user_id")
+		return utils.RecordPriceChange(tx, item.ID, nil, item.Price, models.PriceReasonInitial, &userID, item.CreatedAt)
+	})
+	if utils.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
 		return
 	}
 
+	reindexItems(item.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Item created successfully",
 		"item":    item,
@@ -50,13 +138,247 @@ func CreateItem(c *gin.Context) {
 }
 
 func ListItems(c *gin.Context) {
+	var categories []models.Category
+	if err := utils.DB.Order("position, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
+		return
+	}
+	filters, ok := parseItemFilters(c, categories)
+	if !ok {
+		return
+	}
+
+	query := filters.apply(visibleItems(c), "")
+	switch c.Query("sort") {
+	case "":
+	case "trending":
+		query = query.Scopes(utils.TrendingItems(time.Now()))
+	case "price_asc":
+		query = query.Order("items.price, items.id")
+	case "price_desc":
+		query = query.Order("items.price DESC, items.id")
+	case "rating":
+		query = query.Order("items.rating DESC, items.reviews DESC, items.id")
+	case "newest":
+		query = query.Order("items.created_at DESC, items.id DESC")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort"})
+		return
+	}
+
+	var total int64
+	if err := filters.apply(visibleItems(c), "").Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
+		return
+	}
+
+	response := gin.H{"total": total}
+
+	// Without page or page_size the whole list is returned, as before
+	if c.Query("page") != "" || c.Query("page_size") != "" {
+		page, pageSize := parsePagination(c)
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+		response["page"] = page
+		response["page_size"] = pageSize
+	}
+
 	var items []models.Item
-	if err := utils.DB.Find(&items).Error; err != nil {
+	if err := query.Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
 		return
 	}
+	response["items"] = items
+
+	facets, err := itemFacets(c, filters, categories)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count facets"})
+		return
+	}
+	response["facets"] = facets
+
+	c.JSON(http.StatusOK, response)
+}
 
-	c.JSON(http.StatusOK, gin.H{"items": items})
+func GetItem(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var item models.Item
+	if err := utils.DB.
+		Preload("OptionTypes", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("OptionTypes.Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Variants.Options").
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	// Archived items stay reachable for order history, drafts do not
+	if item.EffectiveStatus(time.Now()) == models.ItemDraft && !canSeeUnpublished(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	recordItemView(c, item.ID)
+
+	c.Header("ETag", utils.FormatETag(item.Version))
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+func UpdateItem(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req UpdateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expectedVersion, hasIfMatch, err := utils.ParseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
+	var item models.Item
+	if err := utils.DB.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
+	}
+	if req.SKU != nil {
+		if *req.SKU != "" && utils.ItemSKUTaken(utils.DB, *req.SKU, item.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+		updates["sku"] = *req.SKU
+	}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Price != nil {
+		updates["price"] = *req.Price
+	}
+	if req.Category != nil || req.CategoryID != nil {
+		name := ""
+		if req.Category != nil {
+			name = *req.Category
+		}
+		category, err := resolveItemCategory(req.CategoryID, name)
+		if err == errCategoryNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve category"})
+			return
+		}
+		if category != nil {
+			updates["category"] = category.Name
+			updates["category_id"] = category.ID
+		} else {
+			updates["category"] = ""
+			updates["category_id"] = nil
+		}
+	}
+	if req.Image != nil {
+		updates["image"] = *req.Image
+	}
+	if req.InStock != nil {
+		updates["in_stock"] = *req.InStock
+	}
+
+	// An explicit status replaces schedule times that have already passed
+	now := time.Now()
+	if req.Status != nil {
+		updates["status"] = *req.Status
+		if item.PublishAt != nil && !now.Before(*item.PublishAt) {
+			updates["publish_at"] = nil
+			item.PublishAt = nil
+		}
+		if item.UnpublishAt != nil && !now.Before(*item.UnpublishAt) {
+			updates["unpublish_at"] = nil
+			item.UnpublishAt = nil
+		}
+	}
+	if req.ClearPublishAt {
+		updates["publish_at"] = nil
+		item.PublishAt = nil
+	} else if req.PublishAt != nil {
+		updates["publish_at"] = *req.PublishAt
+		item.PublishAt = req.PublishAt
+	}
+	if req.ClearUnpublishAt {
+		updates["unpublish_at"] = nil
+		item.UnpublishAt = nil
+	} else if req.UnpublishAt != nil {
+		updates["unpublish_at"] = *req.UnpublishAt
+		item.UnpublishAt = req.UnpublishAt
+	}
+	if !validSchedule(item.PublishAt, item.UnpublishAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be after publish_at"})
+		return
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Only apply the update if nobody changed the item since the client read it
+		query := tx.Model(&models.Item{}).Where("id = ?", item.ID)
+		if hasIfMatch {
+			query = query.Where("version = ?", expectedVersion)
+		}
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errItemVersionMismatch
+		}
+
+		// Keep the old price in the price history
+		if req.Price != nil && *req.Price != item.Price {
+			userID := c.GetUint("user_id")
+			previous := item.Price
+			return utils.RecordPriceChange(tx, item.ID, &previous, *req.Price, models.PriceReasonManual, &userID, now)
+		}
+		return nil
+	})
+	if errors.Is(err, errItemVersionMismatch) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Item has been modified, reload and retry"})
+		return
+	}
+	if utils.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item"})
+		return
+	}
+
+	utils.DB.First(&item, item.ID)
+	reindexItems(item.ID)
+
+	c.Header("ETag", utils.FormatETag(item.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item updated successfully",
+		"item":    item,
+	})
 }
 
 func DeleteItem(c *gin.Context) {
@@ -77,6 +399,7 @@ func DeleteItem(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete item"})
 		return
 	}
+	reindexItems(item.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Item deleted successfully"})
 } 
\ No newline at end of file
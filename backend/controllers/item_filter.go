@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Facet names, also used to leave a filter out of its own facet
+const (
+	facetCategory     = "category"
+	facetPrice        = "price"
+	facetRating       = "rating"
+	facetAvailability = "availability"
+)
+
+// ratingFacetSteps are the "n stars and up" rating buckets
+var ratingFacetSteps = []float64{4, 3, 2, 1}
+
+// itemFilters are the catalog filters of GET /items
+type itemFilters struct {
+	CategoryIDs []uint
+	MinPrice    *float64
+	MaxPrice    *float64
+	MinRating   *float64
+	InStock     *bool
+}
+
+// PriceBucket counts items with Min <= price < Max. The last bucket has
+// no Max.
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+type RatingBucket struct {
+	MinRating float64 `json:"min_rating"`
+	Count     int64   `json:"count"`
+}
+
+type AvailabilityFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
+}
+
+// ItemFacets are counts for a filter sidebar. Each facet applies every
+// filter except its own, so it shows what choosing another value gives.
+type ItemFacets struct {
+	Categories   []*CategoryNode   `json:"categories"`
+	Prices       []PriceBucket     `json:"prices"`
+	Ratings      []RatingBucket    `json:"ratings"`
+	Availability AvailabilityFacet `json:"availability"`
+}
+
+// parseItemFilters reads the filter query parameters. A category filter
+// includes its subcategories.
+func parseItemFilters(c *gin.Context, categories []models.Category) (itemFilters, bool) {
+	var filters itemFilters
+
+	if raw := c.Query("category_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category_id"})
+			return filters, false
+		}
+		filters.CategoryIDs = categoryAndDescendants(categories, uint(id))
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **float64
+	}{
+		{"min_price", &filters.MinPrice},
+		{"max_price", &filters.MaxPrice},
+		{"min_rating", &filters.MinRating},
+	} {
+		if raw := c.Query(param.name); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param.name})
+				return filters, false
+			}
+			*param.target = &value
+		}
+	}
+
+	if raw := c.Query("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid in_stock"})
+			return filters, false
+		}
+		filters.InStock = &inStock
+	}
+
+	return filters, true
+}
+
+func categoryAndDescendants(categories []models.Category, id uint) []uint {
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		for _, category := range categories {
+			if category.ParentID != nil && *category.ParentID == ids[i] {
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// apply adds the filters to an item query, except the one of the facet
+// named by skip
+func (f itemFilters) apply(query *gorm.DB, skip string) *gorm.DB {
+	if f.CategoryIDs != nil && skip != facetCategory {
+		query = query.Where("items.category_id IN ?", f.CategoryIDs)
+	}
+	if skip != facetPrice {
+		if f.MinPrice != nil {
+			query = query.Where("items.price >= ?", *f.MinPrice)
+		}
+		if f.MaxPrice != nil {
+			query = query.Where("items.price <= ?", *f.MaxPrice)
+		}
+	}
+	if f.MinRating != nil && skip != facetRating {
+		query = query.Where("items.rating >= ?", *f.MinRating)
+	}
+	if f.InStock != nil && skip != facetAvailability {
+		query = query.Where("items.in_stock = ?", *f.InStock)
+	}
+	return query
+}
+
+// visibleItems starts an item query limited to what the caller may see
+func visibleItems(c *gin.Context) *gorm.DB {
+	query := utils.DB.Model(&models.Item{})
+	if !canSeeUnpublished(c) {
+		query = query.Scopes(utils.PublishedItems(time.Now()))
+	} else if status := c.Query("status"); status != "" {
+		query = query.Where("items.status = ?", status)
+	}
+	return query
+}
+
+// priceFacetBounds reads the bucket boundaries from FACET_PRICE_BUCKETS
+func priceFacetBounds() []float64 {
+	var bounds []float64
+	for _, part := range strings.Split(utils.GetEnv("FACET_PRICE_BUCKETS", "10,25,50,100,250"), ",") {
+		if bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64); err == nil && bound > 0 &&
+			(len(bounds) == 0 || bound > bounds[len(bounds)-1]) {
+			bounds = append(bounds, bound)
+		}
+	}
+	return bounds
+}
+
+func itemFacets(c *gin.Context, filters itemFilters, categories []models.Category) (ItemFacets, error) {
+	var facets ItemFacets
+
+	var counts []categoryCount
+	if err := filters.apply(visibleItems(c), facetCategory).
+		Select("items.category_id, COUNT(*) AS count").
+		Where("items.category_id IS NOT NULL").
+		Group("items.category_id").Scan(&counts).Error; err != nil {
+		return facets, err
+	}
+	facets.Categories = buildCategoryTree(categories, counts)
+
+	bounds := priceFacetBounds()
+	lower := 0.0
+	var priceCases []bucketCase
+	for _, bound := range bounds {
+		bound := bound
+		priceCases = append(priceCases, bucketCase{"items.price >= ? AND items.price < ?", []interface{}{lower, bound}})
+		facets.Prices = append(facets.Prices, PriceBucket{Min: lower, Max: &bound})
+		lower = bound
+	}
+	priceCases = append(priceCases, bucketCase{"items.price >= ?", []interface{}{lower}})
+	facets.Prices = append(facets.Prices, PriceBucket{Min: lower})
+
+	priceCounts, err := countBuckets(filters.apply(visibleItems(c), facetPrice), priceCases)
+	if err != nil {
+		return facets, err
+	}
+	for i, count := range priceCounts {
+		facets.Prices[i].Count = count
+	}
+
+	ratingCases := make([]bucketCase, len(ratingFacetSteps))
+	for i, step := range ratingFacetSteps {
+		ratingCases[i] = bucketCase{"items.rating >= ?", []interface{}{step}}
+	}
+	ratingCounts, err := countBuckets(filters.apply(visibleItems(c), facetRating), ratingCases)
+	if err != nil {
+		return facets, err
+	}
+	for i, step := range ratingFacetSteps {
+		facets.Ratings = append(facets.Ratings, RatingBucket{MinRating: step, Count: ratingCounts[i]})
+	}
+
+	var availability []struct {
+		InStock bool
+		Count   int64
+	}
+	if err := filters.apply(visibleItems(c), facetAvailability).
+		Select("items.in_stock, COUNT(*) AS count").
+		Group("items.in_stock").Scan(&availability).Error; err != nil {
+		return facets, err
+	}
+	for _, row := range availability {
+		if row.InStock {
+			facets.Availability.InStock = row.Count
+		} else {
+			facets.Availability.OutOfStock = row.Count
+		}
+	}
+
+	return facets, nil
+}
+
+// bucketCase is a condition an item is counted under
+type bucketCase struct {
+	condition string
+	args      []interface{}
+}
+
+// countBuckets counts the items of query matching each case in one query
+func countBuckets(query *gorm.DB, cases []bucketCase) ([]int64, error) {
+	columns := make([]string, len(cases))
+	var args []interface{}
+	for i, bucket := range cases {
+		columns[i] = "COALESCE(SUM(CASE WHEN " + bucket.condition + " THEN 1 ELSE 0 END), 0)"
+		args = append(args, bucket.args...)
+	}
+
+	counts := make([]int64, len(cases))
+	targets := make([]interface{}, len(cases))
+	for i := range counts {
+		targets[i] = &counts[i]
+	}
+	err := query.Select(strings.Join(columns, ", "), args...).Row().Scan(targets...)
+	return counts, err
+}
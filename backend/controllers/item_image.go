@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReorderItemImagesRequest struct {
+	ImageIDs []uint `json:"image_ids" binding:"required"`
+}
+
+// uploadCacheControl applies to served uploads. Every upload gets a new key,
+// so files never change and can be cached indefinitely.
+const uploadCacheControl = "public, max-age=31536000, immutable"
+
+func ListItemImages(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var images []models.ItemImage
+	if err := utils.DB.Where("item_id = ?", item.ID).Order("position, id").Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": images})
+}
+
+func UploadItemImage(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	maxBytes := int64(utils.GetEnvInt("IMAGE_MAX_UPLOAD_MB", 5)) << 20
+	// Leave some room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+(1<<20))
+
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An image file is required in the image field"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	if header.Size > maxBytes || int64(len(data)) > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return
+	}
+
+	// Trust the file contents, not the declared content type
+	contentType := http.DetectContentType(data)
+	ext, ok := utils.ImageExtension(contentType)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Images must be JPEG, PNG, GIF or WebP"})
+		return
+	}
+
+	img, format, err := utils.DecodeImage(data)
+	if errors.Is(err, utils.ErrImageTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image dimensions are too large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image could not be decoded"})
+		return
+	}
+
+	token, _, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	base := fmt.Sprintf("items/%d/%s", item.ID, token[:16])
+
+	store := utils.GetStorage()
+	var keys []string
+	save := func(key string, content []byte) error {
+		if err := store.Save(key, bytes.NewReader(content)); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	}
+
+	originalKey := base + "/original." + ext
+	thumbnails := models.ImageThumbnails{}
+	err = save(originalKey, data)
+	for _, size := range utils.ThumbnailSizes() {
+		if err != nil {
+			break
+		}
+		var thumb []byte
+		var thumbExt string
+		thumb, thumbExt, err = utils.EncodeThumbnail(utils.Thumbnail(img, size), format)
+		if err == nil {
+			key := fmt.Sprintf("%s/%d.%s", base, size, thumbExt)
+			if err = save(key, thumb); err == nil {
+				thumbnails[strconv.Itoa(size)] = store.URL(key)
+			}
+		}
+	}
+	if err != nil {
+		deleteStoredFiles(keys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	image := models.ItemImage{
+		ItemID:      item.ID,
+		URL:         store.URL(originalKey),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Size:        int64(len(data)),
+		Thumbnails:  thumbnails,
+		StorageKeys: strings.Join(keys, ","),
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.ItemImage{}).Where("item_id = ?", item.ID).Count(&count)
+
+		// Append by default, or make room at the requested position
+		image.Position = int(count)
+		if position, err := strconv.Atoi(c.PostForm("position")); err == nil && position >= 0 && position < int(count) {
+			image.Position = position
+			if err := tx.Model(&models.ItemImage{}).
+				Where("item_id = ? AND position >= ?", item.ID, position).
+				Update("position", gorm.Expr("position + 1")).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&image).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, item.ID, "")
+	})
+	if err != nil {
+		deleteStoredFiles(keys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Image uploaded successfully",
+		"image":   image,
+	})
+}
+
+func ReorderItemImages(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var req ReorderItemImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var images []models.ItemImage
+	utils.DB.Where("item_id = ?", item.ID).Find(&images)
+
+	// The new order must name every image of the item exactly once
+	known := map[uint]bool{}
+	for _, image := range images {
+		known[image.ID] = true
+	}
+	if len(req.ImageIDs) != len(images) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the item once"})
+		return
+	}
+	for _, id := range req.ImageIDs {
+		if !known[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the item once"})
+			return
+		}
+		delete(known, id)
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range req.ImageIDs {
+			if err := tx.Model(&models.ItemImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return syncPrimaryImage(tx, item.ID, "")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+		return
+	}
+
+	utils.DB.Where("item_id = ?", item.ID).Order("position, id").Find(&images)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Images reordered successfully",
+		"images":  images,
+	})
+}
+
+func DeleteItemImage(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var image models.ItemImage
+	if err := utils.DB.Where("id = ? AND item_id = ?", c.Param("imageId"), item.ID).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&image).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ItemImage{}).
+			Where("item_id = ? AND position > ?", item.ID, image.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImage(tx, item.ID, image.URL)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+
+	deleteStoredFiles(strings.Split(image.StorageKeys, ","))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// ServeUpload serves stored files with long-lived cache headers
+func ServeUpload(c *gin.Context) {
+	key, err := utils.CleanStorageKey(c.Param("filepath"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	file, err := utils.GetStorage().Open(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.DataFromReader(http.StatusOK, -1, contentType, file, map[string]string{
+		"Cache-Control":          uploadCacheControl,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// syncPrimaryImage points Item.Image at the first image of the item. When
+// the item has no images left and still shows removedURL, the image is
+// cleared.
+func syncPrimaryImage(tx *gorm.DB, itemID uint, removedURL string) error {
+	var first models.ItemImage
+	err := tx.Where("item_id = ?", itemID).Order("position, id").First(&first).Error
+	if err == gorm.ErrRecordNotFound {
+		if removedURL == "" {
+			return nil
+		}
+		return tx.Model(&models.Item{}).Where("id = ? AND image = ?", itemID, removedURL).
+			Updates(map[string]interface{}{"image": "", "version": gorm.Expr("version + 1")}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Item{}).Where("id = ? AND image <> ?", itemID, first.URL).
+		Updates(map[string]interface{}{"image": first.URL, "version": gorm.Expr("version + 1")}).Error
+}
+
+// deleteStoredFiles removes files from storage, logging failures since the
+// database no longer refers to them
+func deleteStoredFiles(keys []string) {
+	store := utils.GetStorage()
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := store.Delete(key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// guestTokenHeader identifies guests across requests. Guests opt in to a
+// history by asking for a token with POST /guest-token.
+const guestTokenHeader = "X-Guest-Token"
+
+// RecentlyViewedItem is an entry of the recently viewed list
+type RecentlyViewedItem struct {
+	Item     models.Item `json:"item"`
+	ViewedAt time.Time   `json:"viewed_at"`
+}
+
+// currentViewer returns the signed in user or the hash of the guest token
+func currentViewer(c *gin.Context) (uint, string, bool) {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return userID, "", true
+	}
+	if token := c.GetHeader(guestTokenHeader); token != "" {
+		return 0, utils.HashToken(token), true
+	}
+	return 0, "", false
+}
+
+// recordItemView adds the item to the viewer's history. Views without a
+// token or with one the server never issued are ignored, so views can't be
+// inflated by dropping or making up tokens, and crawlers without a token
+// leave nothing behind. Failures are only logged so they never break
+// viewing the item.
+func recordItemView(c *gin.Context, itemID uint) {
+	now := time.Now()
+	userID, guestTokenHash, ok := currentViewer(c)
+	if !ok {
+		return
+	}
+
+	if userID == 0 {
+		known, err := utils.TouchGuestToken(utils.DB, guestTokenHash, now)
+		if err != nil {
+			log.Println("Failed to check guest token:", err)
+		}
+		if !known {
+			return
+		}
+	}
+	if err := utils.RecordItemView(utils.DB, userID, guestTokenHash, itemID, now); err != nil {
+		log.Println("Failed to record item view:", err)
+	}
+}
+
+// IssueGuestToken gives a guest a token to send as X-Guest-Token, so their
+// item views are recorded
+func IssueGuestToken(c *gin.Context) {
+	token, err := utils.IssueGuestToken(utils.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue guest token"})
+		return
+	}
+
+	c.Header(guestTokenHeader, token)
+	c.JSON(http.StatusCreated, gin.H{"guest_token": token})
+}
+
+func GetRecentlyViewed(c *gin.Context) {
+	userID, guestTokenHash, ok := currentViewer(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication or guest token required"})
+		return
+	}
+
+	var views []models.ItemView
+	if err := utils.DB.Scopes(utils.Viewer(userID, guestTokenHash)).
+		Preload("Item").
+		Order("viewed_at DESC, id DESC").
+		Limit(utils.RecentlyViewedLimit()).
+		Find(&views).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recently viewed items"})
+		return
+	}
+
+	// Items deleted or unpublished since they were viewed are left out
+	now := time.Now()
+	items := []RecentlyViewedItem{}
+	for _, view := range views {
+		if view.Item.ID == 0 || (view.Item.EffectiveStatus(now) == models.ItemDraft && !canSeeUnpublished(c)) {
+			continue
+		}
+		items = append(items, RecentlyViewedItem{Item: view.Item, ViewedAt: view.ViewedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+	"shopping-cart/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys used to verify issued tokens
+func GetJWKS(c *gin.Context) {
+	jwks, err := utils.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxMetricPoints = 1000
+
+// defaultMetricRanges is how far back the dashboard looks without from
+var defaultMetricRanges = map[string]func(to time.Time) time.Time{
+	utils.PeriodHour:  func(to time.Time) time.Time { return to.Add(-48 * time.Hour) },
+	utils.PeriodDay:   func(to time.Time) time.Time { return to.AddDate(0, 0, -30) },
+	utils.PeriodWeek:  func(to time.Time) time.Time { return to.AddDate(0, 0, -12*7) },
+	utils.PeriodMonth: func(to time.Time) time.Time { return to.AddDate(-1, 0, 0) },
+}
+
+// GetMetrics returns dashboard time series read from the metric rollups,
+// which the background job keeps up to date
+func GetMetrics(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", utils.PeriodDay)
+	defaultFrom, ok := defaultMetricRanges[granularity]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be hour, day, week or month"})
+		return
+	}
+
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		if to, ok = parseReportTime(raw, true); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	from := defaultFrom(to)
+	if raw := c.Query("from"); raw != "" {
+		if from, ok = parseReportTime(raw, false); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	periods := 0
+	for start := utils.PeriodStart(from, granularity); start.Before(to); start = utils.NextPeriod(start, granularity) {
+		if periods++; periods > maxMetricPoints {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range covers more than %d periods, use a coarser granularity", maxMetricPoints)})
+			return
+		}
+	}
+
+	series, totals, err := utils.MetricsSeries(utils.DB, from, to, granularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"granularity": granularity,
+		"from":        utils.PeriodStart(from, granularity),
+		"to":          to.UTC(),
+		"series":      series,
+		"totals":      totals,
+	})
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL bounds how long a user can take at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie ties a login state to the browser that started the flow,
+// so a callback URL from someone else's login cannot be replayed here
+const oidcStateCookie = "oidc_state"
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// OIDCLogin starts an authorization code flow with PKCE and redirects the
+// browser to the provider
+func OIDCLogin(c *gin.Context) {
+	provider, err := utils.GetOIDCProvider()
+	if errors.Is(err, utils.ErrOIDCNotConfigured) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+	if err != nil {
+		log.Println("OIDC discovery failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "OIDC provider unavailable"})
+		return
+	}
+
+	state, stateHash, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, _, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, challenge, err := utils.NewPKCEVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	// Drop abandoned login attempts
+	utils.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	loginState := models.OIDCLoginState{
+		StateHash:    stateHash,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := utils.DB.Create(&loginState).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	// Lax still sends the cookie on the provider's top-level redirect back
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/auth/oidc", "", oidcCookieSecure(c, provider), true)
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce, challenge))
+}
+
+// oidcCookieSecure marks the state cookie Secure whenever the flow runs
+// over HTTPS
+func oidcCookieSecure(c *gin.Context, provider *utils.OIDCProvider) bool {
+	return c.Request.TLS != nil || strings.HasPrefix(provider.RedirectURL, "https://")
+}
+
+// OIDCCallback completes the flow: it redeems the code, verifies the ID
+// token, finds or creates the linked user and logs them in like Login does
+func OIDCCallback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "OIDC login failed: " + errParam})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	// Only the browser that started this login may finish it
+	cookieState, err := c.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookieState), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	// The state is single use: only the request that deletes it may go on
+	var loginState models.OIDCLoginState
+	if err := utils.DB.Where("state_hash = ? AND expires_at > ?", utils.HashToken(state), time.Now()).First(&loginState).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	if result := utils.DB.Delete(&loginState); result.Error != nil || result.RowsAffected != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	provider, err := utils.GetOIDCProvider()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", oidcCookieSecure(c, provider), true)
+
+	idToken, err := provider.Exchange(code, loginState.CodeVerifier)
+	if err != nil {
+		log.Println("OIDC token exchange failed:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(idToken, loginState.Nonce)
+	if err != nil {
+		log.Println("OIDC id_token rejected:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	user, err := findOrCreateOIDCUser(provider.Name, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in user"})
+		return
+	}
+
+	completeLogin(c, user)
+}
+
+// findOrCreateOIDCUser resolves the local user for an external identity.
+// Known identities log straight in; otherwise an account whose verified
+// email matches a verified provider email is linked, and failing that a new
+// account is created. An identity left behind by a deleted account counts as
+// not linked.
+func findOrCreateOIDCUser(providerName string, claims *utils.OIDCClaims) (*models.User, error) {
+	var user models.User
+
+	var identity models.UserIdentity
+	if err := utils.DB.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error; err == nil {
+		err := utils.DB.First(&user, identity.UserID).Error
+		if err == nil {
+			return &user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err := utils.DB.Delete(&identity).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		linked := false
+		if claims.EmailVerified && claims.Email != "" {
+			if err := tx.Where("email = ? AND email_verified_at IS NOT NULL", claims.Email).First(&user).Error; err == nil {
+				linked = true
+			}
+		}
+
+		if !linked {
+			username, err := uniqueUsername(tx, claims)
+			if err != nil {
+				return err
+			}
+
+			// External accounts have no usable local password
+			user = models.User{
+				Username:    username,
+				Password:    "!",
+				DisplayName: claims.Name,
+			}
+
+			// Only claim the email if no other account uses it
+			var existing models.User
+			if claims.Email != "" && tx.Where("email = ?", claims.Email).First(&existing).Error != nil {
+				user.Email = claims.Email
+				if claims.EmailVerified {
+					now := time.Now()
+					user.EmailVerifiedAt = &now
+				}
+			}
+
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// uniqueUsername derives a free username from the provider's claims
+func uniqueUsername(tx *gorm.DB, claims *utils.OIDCClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = strings.Trim(usernameUnsafeChars.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
@@ -1,19 +1,51 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"shopping-cart/models"
 	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending paid shipped delivered cancelled"`
+}
+
+type CreateOrderRequest struct {
+	BillingAddress  string `json:"billing_address" binding:"max=500"`
+	ShippingAddress string `json:"shipping_address" binding:"max=500"`
+}
+
 func CreateOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
+	// Addresses are optional, so an empty body is fine
+	var req CreateOrderRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Optionally require a verified email before ordering
+	if utils.GetEnvBool("REQUIRE_VERIFIED_EMAIL_FOR_ORDERS", false) {
+		var user models.User
+		if err := utils.DB.First(&user, userID).Error; err != nil || user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address must be verified before placing an order"})
+			return
+		}
+	}
+
 	// Get user's active cart
 	var cart models.Cart
-	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").Preload("Items.Item").First(&cart).Error; err != nil {
+	if err := utils.DB.Where("user_id = ? AND status = ?", userID, "active").Preload("Items.Item").Preload("Items.Variant").First(&cart).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
 		return
 	}
@@ -23,28 +55,106 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Calculate total
+	// Items may have been unpublished since they were added to the cart
+	now := time.Now()
+	for _, item := range cart.Items {
+		if item.Item.ID == 0 || item.Item.EffectiveStatus(now) != models.ItemActive {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "An item in the cart is no longer available",
+				"item_id": item.ItemID,
+			})
+			return
+		}
+	}
+
+	// Calculate total and snapshot the lines. Prices are taken now, since
+	// sales and scheduled changes may have moved them since the items were
+	// added.
 	var total float64
+	lines := make([]models.OrderItem, 0, len(cart.Items))
 	for _, item := range cart.Items {
-		total += item.Price * float64(item.Quantity)
+		price := item.CurrentPrice()
+		if price != item.Price {
+			// Keep the cart in line, also for when checkout fails below
+			if err := utils.DB.Model(&models.CartItem{}).Where("id = ?", item.ID).Update("price", price).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+				return
+			}
+		}
+		total += price * float64(item.Quantity)
+
+		line := models.OrderItem{
+			ItemID:    item.ItemID,
+			VariantID: item.VariantID,
+			Name:      item.Item.Name,
+			Quantity:  item.Quantity,
+			Price:     price,
+			SKU:       item.Item.SKU,
+		}
+		if item.Variant != nil {
+			line.SKU = item.Variant.SKU
+		}
+		lines = append(lines, line)
 	}
 
 	// Create order from cart (as per ERD)
 	order := models.Order{
 		CartID: cart.ID,
 		UserID: userID,
+		Items:  lines,
 		Total:  total,
 		Status: "pending",
+
+		BillingAddress:  strings.TrimSpace(req.BillingAddress),
+		ShippingAddress: strings.TrimSpace(req.ShippingAddress),
 	}
 
-	if err := utils.DB.Create(&order).Error; err != nil {
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Clearing the cart below would drop lines changed since it was
+		// read, so only go ahead if its version is still the one read
+		if err := bumpCartVersion(tx, &cart, cart.Version, true); err != nil {
+			return err
+		}
+
+		// Take variant stock, failing if another order got there first
+		for _, line := range lines {
+			if line.VariantID == nil {
+				continue
+			}
+			result := tx.Model(&models.Variant{}).
+				Where("id = ? AND stock >= ?", *line.VariantID, line.Quantity).
+				Update("stock", gorm.Expr("stock - ?", line.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errOutOfStock
+			}
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := utils.CloseCartSession(tx, cart.ID, order.ID); err != nil {
+			return err
+		}
+
+		// Clear cart items after order creation
+		return tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	})
+	if errors.Is(err, errCartVersionMismatch) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart has been modified, reload and retry"})
+		return
+	}
+	if errors.Is(err, errOutOfStock) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Not enough stock for an item in the cart"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
-	// Clear cart items after order creation
-	utils.DB.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{})
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Order created successfully",
 		"order_id": order.ID,
@@ -56,7 +166,7 @@ func ListOrders(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
 	var orders []models.Order
-	if err := utils.DB.Where("user_id = ?", userID).Preload("Cart.Items.Item").Find(&orders).Error; err != nil {
+	if err := utils.DB.Where("user_id = ?", userID).Preload("Items.Variant.Options").Preload("Invoice").Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
@@ -64,12 +174,79 @@ func ListOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"orders": orders})
 }
 
+// ListAllOrders lists orders matching the report filters. Like GET /items
+// it only paginates when page or page_size is given.
 func ListAllOrders(c *gin.Context) {
+	filter, ok := parseOrderFilter(c, nil)
+	if !ok {
+		return
+	}
+
+	query := filter.Apply(utils.DB.Model(&models.Order{}))
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	response := gin.H{"total": total}
+	query = query.Preload("User").Preload("Items.Variant.Options").Preload("Invoice").Order("orders.id")
+	if c.Query("page") != "" || c.Query("page_size") != "" {
+		page, pageSize := parsePagination(c)
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+		response["page"] = page
+		response["page_size"] = pageSize
+	}
+
 	var orders []models.Order
-	if err := utils.DB.Preload("User").Preload("Cart.Items.Item").Find(&orders).Error; err != nil {
+	if err := query.Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
+	response["orders"] = orders
 
-	c.JSON(http.StatusOK, gin.H{"orders": orders})
-} 
\ No newline at end of file
+	c.JSON(http.StatusOK, response)
+}
+
+func UpdateOrderStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order models.Order
+	if err := utils.DB.First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	// A paid order gets its invoice number in the same transaction, so a
+	// failed update never leaves a gap in the sequence
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&order).Update("status", req.Status).Error; err != nil {
+			return err
+		}
+		if !utils.IsInvoiceStatus(req.Status) {
+			return nil
+		}
+		invoice, err := utils.IssueInvoice(tx, order, time.Now())
+		order.Invoice = &invoice
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order updated successfully",
+		"order":   order,
+	})
+}
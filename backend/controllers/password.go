@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// errResetTokenUsed is returned when a reset token was used or expired
+// after it was looked up
+var errResetTokenUsed = errors.New("reset token already used")
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// getPasswordResetTTL returns how long a reset token stays valid
+func getPasswordResetTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("PASSWORD_RESET_TTL_MINUTES", 60)) * time.Minute
+}
+
+func ChangePassword(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Check old password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Old password is incorrect"})
+		return
+	}
+
+	if err := utils.ValidatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := setPassword(utils.DB, &user, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Issue a fresh token for this session; all older tokens are now revoked
+	token, err := utils.GenerateToken(user.ID, user.TokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	utils.DB.Model(&user).Update("token", token)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+		"token":   token,
+	})
+}
+
+func RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Username == "" && req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email is required"})
+		return
+	}
+
+	// The response is the same whether or not the account exists
+	response := gin.H{"message": "If the account exists, a password reset email has been sent"}
+
+	var user models.User
+	query := utils.DB.Where("username = ?", req.Username)
+	if req.Username == "" {
+		query = utils.DB.Where("email = ?", req.Email)
+	}
+	if err := query.First(&user).Error; err != nil || user.Email == "" {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	token, tokenHash, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(getPasswordResetTTL()),
+	}
+	if err := utils.DB.Create(&resetToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	resetURL := utils.GetEnv("FRONTEND_URL", "http://localhost:3000") + "/reset-password?token=" + token
+	err = utils.SendMail(utils.MailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes.\n\n%s\n\nReset token: %s\n\nIf you did not ask for this, you can ignore this email.",
+			user.Username, int(getPasswordResetTTL().Minutes()), resetURL, token),
+	})
+	if err != nil {
+		log.Println("Failed to send password reset email:", err)
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+func ConfirmPasswordReset(c *gin.Context) {
+	var req PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+	err := utils.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(req.Token), time.Now()).
+		First(&resetToken).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if err := utils.ValidatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the token so that concurrent requests can only use it once
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", resetToken.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errResetTokenUsed
+		}
+
+		// Consume every other outstanding reset token for the user
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", resetToken.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, resetToken.UserID).Error; err != nil {
+			return errResetTokenUsed
+		}
+		return setPassword(tx, &user, req.NewPassword)
+	})
+	if errors.Is(err, errResetTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// setPassword hashes and stores a new password, clears any login lockout and
+// bumps the token version so that every existing session is revoked
+func setPassword(db *gorm.DB, user *models.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	user.TokenVersion++
+	user.Token = ""
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+
+	return db.Model(user).Select("password", "token_version", "token", "failed_login_attempts", "locked_until").Updates(user).Error
+}
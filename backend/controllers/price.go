@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SchedulePriceRequest struct {
+	Price    float64    `json:"price" binding:"required,gt=0"`
+	StartsAt time.Time  `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// priceHistoryLimit caps the history returned by GetItemPrices
+const priceHistoryLimit = 100
+
+func GetItemPrices(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var history []models.PriceHistory
+	if err := utils.DB.Where("item_id = ?", item.ID).
+		Order("effective_from DESC, id DESC").Limit(priceHistoryLimit).
+		Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	response := gin.H{
+		"item_id":      item.ID,
+		"price":        item.Price,
+		"was_price":    nil,
+		"sale_ends_at": nil,
+		"history":      history,
+	}
+
+	// A running sale shows the price it will return to as the "was" price
+	var sale models.ScheduledPrice
+	if err := utils.DB.Where("item_id = ? AND status = ?", item.ID, models.PriceScheduleActive).
+		Order("starts_at DESC").First(&sale).Error; err == nil && sale.OriginalPrice != nil && sale.Price == item.Price {
+		response["was_price"] = *sale.OriginalPrice
+		response["sale_ends_at"] = sale.EndsAt
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ListScheduledPrices(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var schedules []models.ScheduledPrice
+	if err := utils.DB.Where("item_id = ?", item.ID).Order("starts_at DESC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled prices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduled_prices": schedules})
+}
+
+func SchedulePriceChange(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var req SchedulePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if req.EndsAt != nil && (!req.EndsAt.After(req.StartsAt) || !req.EndsAt.After(now)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at and in the future"})
+		return
+	}
+
+	// Sales of one item cannot overlap, since each reverts to its own price
+	if req.EndsAt != nil {
+		var overlapping int64
+		utils.DB.Model(&models.ScheduledPrice{}).
+			Where("item_id = ? AND status IN ? AND ends_at IS NOT NULL", item.ID,
+				[]string{models.PriceSchedulePending, models.PriceScheduleActive}).
+			Where("starts_at < ? AND ends_at > ?", *req.EndsAt, req.StartsAt).
+			Count(&overlapping)
+		if overlapping > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another sale overlaps this period"})
+			return
+		}
+	}
+
+	schedule := models.ScheduledPrice{
+		ItemID:      item.ID,
+		Price:       req.Price,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		Status:      models.PriceSchedulePending,
+		CreatedByID: userID,
+	}
+	if err := utils.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule price change"})
+		return
+	}
+
+	// Changes starting now are applied right away instead of on the next run
+	if !req.StartsAt.After(now) {
+		if err := utils.ApplyScheduledPrices(utils.DB, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply price change"})
+			return
+		}
+		utils.DB.First(&schedule, schedule.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "Price change scheduled successfully",
+		"scheduled_price": schedule,
+	})
+}
+
+func CancelScheduledPrice(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var schedule models.ScheduledPrice
+	if err := utils.DB.Where("id = ? AND item_id = ?", c.Param("scheduleId"), item.ID).First(&schedule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled price not found"})
+		return
+	}
+
+	if schedule.Status != models.PriceSchedulePending && schedule.Status != models.PriceScheduleActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Price change has already finished"})
+		return
+	}
+
+	// Cancelling a running sale restores the original price now
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		return utils.CancelScheduledPrice(tx, schedule, time.Now())
+	})
+	if errors.Is(err, utils.ErrPriceScheduleChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Price change has already started or finished, reload and retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel price change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Price change cancelled successfully"})
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UserResponse is the public view of a user. It is built field by field so
+// credentials can never end up in a response.
+type UserResponse struct {
+	ID                uint               `json:"id"`
+	Username          string             `json:"username"`
+	Role              string             `json:"role"`
+	Email             string             `json:"email"`
+	EmailVerified     bool               `json:"email_verified"`
+	DisplayName       string             `json:"display_name"`
+	Preferences       models.Preferences `json:"preferences"`
+	CartID            *uint              `json:"cart_id"`
+	TwoFactorEnabled  bool               `json:"two_factor_enabled"`
+	TwoFactorRequired bool               `json:"two_factor_required"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+// PublicUserResponse is what other users get to see of an account. Contact
+// details, preferences and security settings stay private to the owner.
+type PublicUserResponse struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UpdateProfileRequest struct {
+	Email       *string            `json:"email" binding:"omitempty,email"`
+	DisplayName *string            `json:"display_name" binding:"omitempty,max=100"`
+	Preferences models.Preferences `json:"preferences"`
+}
+
+func newUserResponse(user models.User) UserResponse {
+	preferences := user.Preferences
+	if preferences == nil {
+		preferences = models.Preferences{}
+	}
+
+	return UserResponse{
+		ID:                user.ID,
+		Username:          user.Username,
+		Role:              user.Role,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerifiedAt != nil,
+		DisplayName:       user.DisplayName,
+		Preferences:       preferences,
+		CartID:            user.CartID,
+		TwoFactorEnabled:  user.TwoFactorEnabled,
+		TwoFactorRequired: utils.TwoFactorRequired(user),
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+	}
+}
+
+func newPublicUserResponse(user models.User) PublicUserResponse {
+	return PublicUserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		DisplayName: user.DisplayName,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+func GetProfile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": newUserResponse(user)})
+}
+
+func UpdateProfile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Email != nil && *req.Email != user.Email {
+		// Check if email is already used by another account
+		var existingUser models.User
+		if *req.Email != "" && utils.DB.Where("email = ? AND id <> ?", *req.Email, user.ID).First(&existingUser).Error == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		updates["email"] = *req.Email
+		updates["email_verified_at"] = nil
+	}
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Preferences != nil {
+		// Merge so clients can change one preference at a time
+		preferences := models.Preferences{}
+		for key, value := range user.Preferences {
+			preferences[key] = value
+		}
+		for key, value := range req.Preferences {
+			if value == nil {
+				delete(preferences, key)
+			} else {
+				preferences[key] = value
+			}
+		}
+		updates["preferences"] = preferences
+	}
+
+	if len(updates) > 0 {
+		if err := utils.DB.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		utils.DB.First(&user, user.ID)
+
+		// A new address has to be verified again
+		if _, changed := updates["email"]; changed && user.Email != "" {
+			if err := sendVerificationEmail(&user); err != nil {
+				log.Println("Failed to send verification email:", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"user":    newUserResponse(user),
+	})
+}
+
+// DeleteAccount anonymizes the user instead of removing the row so that
+// orders keep pointing at a valid user
+func DeleteAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Empty the active cart
+		var cart models.Cart
+		if err := tx.Where("user_id = ? AND status = ?", user.ID, "active").First(&cart).Error; err == nil {
+			if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// Browsing history is personal data too
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.ItemView{}).Error; err != nil {
+			return err
+		}
+
+		// Wishlists are personal data and must stop being shared
+		if err := tx.Where("wishlist_id IN (?)", tx.Model(&models.Wishlist{}).Select("id").Where("user_id = ?", user.ID)).
+			Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Wishlist{}).Error; err != nil {
+			return err
+		}
+
+		// Unlink external logins so the provider account can sign up again
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+
+		// Scrub personal data, lock out the password and revoke all sessions
+		updates := map[string]interface{}{
+			"username":          fmt.Sprintf("deleted-user-%d", user.ID),
+			"password":          "!",
+			"token":             "",
+			"email":             "",
+			"email_verified_at": nil,
+			"display_name":      "",
+			"preferences":       nil,
+			"token_version":     gorm.Expr("token_version + 1"),
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
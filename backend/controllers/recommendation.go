@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRecommendationLimit = 10
+	maxRecommendationLimit     = 50
+)
+
+// RecommendedItem is a suggested item with why it was suggested
+type RecommendedItem struct {
+	Item         models.Item `json:"item"`
+	Score        float64     `json:"score"`
+	CoPurchases  int         `json:"co_purchases"`
+	SameCategory bool        `json:"same_category"`
+}
+
+type recommendationRow struct {
+	RelatedItemID uint
+	Score         float64
+	CoPurchases   int
+	SameCategory  bool
+}
+
+func parseRecommendationLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		return defaultRecommendationLimit
+	}
+	if limit > maxRecommendationLimit {
+		return maxRecommendationLimit
+	}
+	return limit
+}
+
+// recommendationQuery reads precomputed recommendations, keeping only
+// items that can be bought right now
+func recommendationQuery() *gorm.DB {
+	return utils.DB.Table("item_recommendations").
+		Joins("JOIN items ON items.id = item_recommendations.related_item_id AND items.deleted_at IS NULL").
+		Scopes(utils.PublishedItems(time.Now())).
+		Where("items.in_stock = ?", true)
+}
+
+// loadRecommendedItems loads the items of rows, keeping their order
+func loadRecommendedItems(rows []recommendationRow) ([]RecommendedItem, error) {
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.RelatedItemID
+	}
+	var items []models.Item
+	if err := utils.DB.Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	byID := map[uint]models.Item{}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	recommended := []RecommendedItem{}
+	for _, row := range rows {
+		if item, ok := byID[row.RelatedItemID]; ok {
+			recommended = append(recommended, RecommendedItem{
+				Item:         item,
+				Score:        row.Score,
+				CoPurchases:  row.CoPurchases,
+				SameCategory: row.SameCategory,
+			})
+		}
+	}
+	return recommended, nil
+}
+
+func GetRelatedItems(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var rows []recommendationRow
+	if err := recommendationQuery().
+		Select("item_recommendations.related_item_id, item_recommendations.score, item_recommendations.co_purchases, item_recommendations.same_category").
+		Where("item_recommendations.item_id = ?", item.ID).
+		Order("item_recommendations.score DESC, item_recommendations.related_item_id").
+		Limit(parseRecommendationLimit(c)).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related items"})
+		return
+	}
+
+	related, err := loadRecommendedItems(rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch related items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": related})
+}
+
+// GetCartRecommendations suggests items related to everything in the
+// active cart, adding up their scores and leaving out what is already in it
+func GetCartRecommendations(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var cartItemIDs []uint
+	utils.DB.Model(&models.CartItem{}).
+		Joins("JOIN carts ON carts.id = cart_items.cart_id AND carts.deleted_at IS NULL").
+		Where("carts.user_id = ? AND carts.status = ?", userID, "active").
+		Distinct().Pluck("cart_items.item_id", &cartItemIDs)
+	if len(cartItemIDs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"items": []RecommendedItem{}})
+		return
+	}
+
+	var rows []recommendationRow
+	if err := recommendationQuery().
+		Select("item_recommendations.related_item_id, SUM(item_recommendations.score) AS score, SUM(item_recommendations.co_purchases) AS co_purchases, MAX(item_recommendations.same_category) AS same_category").
+		Where("item_recommendations.item_id IN ? AND item_recommendations.related_item_id NOT IN ?", cartItemIDs, cartItemIDs).
+		Group("item_recommendations.related_item_id").
+		Order("score DESC, item_recommendations.related_item_id").
+		Limit(parseRecommendationLimit(c)).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		return
+	}
+
+	recommended, err := loadRecommendedItems(rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": recommended})
+}
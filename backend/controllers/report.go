@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultReportLimit = 10
+	maxReportLimit     = 100
+)
+
+var orderStatuses = map[string]bool{
+	models.OrderPending:   true,
+	models.OrderPaid:      true,
+	models.OrderShipped:   true,
+	models.OrderDelivered: true,
+	models.OrderCancelled: true,
+}
+
+var orderExportContentTypes = map[string]string{
+	utils.OrderExportCSV:   "text/csv",
+	utils.OrderExportJSONL: "application/x-ndjson",
+}
+
+// parseReportTime reads a date or an RFC 3339 time. A date given as the end
+// of a range covers that whole day.
+func parseReportTime(value string, end bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, false
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// parseOrderFilter reads the from, to, status and user_id query parameters.
+// Without status the given default statuses apply.
+func parseOrderFilter(c *gin.Context, defaultStatuses []string) (utils.OrderFilter, bool) {
+	filter := utils.OrderFilter{Statuses: defaultStatuses}
+
+	for _, param := range []struct {
+		name   string
+		end    bool
+		target **time.Time
+	}{
+		{"from", false, &filter.From},
+		{"to", true, &filter.To},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		t, ok := parseReportTime(raw, param.end)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param.name + " must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return filter, false
+		}
+		*param.target = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return filter, false
+	}
+
+	if raw := c.Query("status"); raw != "" {
+		filter.Statuses = nil
+		for _, status := range strings.Split(raw, ",") {
+			status = strings.TrimSpace(status)
+			if !orderStatuses[status] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown order status: " + status})
+				return filter, false
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return filter, false
+		}
+		filter.UserID = uint(id)
+	}
+
+	return filter, true
+}
+
+// parseReportLimit reads the limit query parameter of ranking reports
+func parseReportLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		return defaultReportLimit
+	}
+	return min(limit, maxReportLimit)
+}
+
+// ExportOrders streams the filtered orders as CSV or JSON Lines, one row
+// per order line
+func ExportOrders(c *gin.Context) {
+	format := c.DefaultQuery("format", utils.OrderExportCSV)
+	contentType, ok := orderExportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrUnknownOrderExportFormat.Error()})
+		return
+	}
+	filter, ok := parseOrderFilter(c, nil)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="orders.`+format+`"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged
+	if err := utils.ExportOrders(utils.DB, c.Writer, format, filter); err != nil {
+		log.Println("Failed to export orders:", err)
+	}
+}
+
+// GetRevenueReport returns revenue, tax, order counts and average order
+// value per day, week or month. Only paid orders count unless status is
+// given.
+func GetRevenueReport(c *gin.Context) {
+	period := c.DefaultQuery("period", utils.PeriodDay)
+	if period != utils.PeriodDay && period != utils.PeriodWeek && period != utils.PeriodMonth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day, week or month"})
+		return
+	}
+	filter, ok := parseOrderFilter(c, utils.InvoiceStatuses)
+	if !ok {
+		return
+	}
+
+	points, summary, err := utils.RevenueByPeriod(utils.DB, filter, period, maxMetricPoints)
+	if errors.Is(err, utils.ErrTooManyPeriods) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range covers more than %d periods, use a longer period", maxMetricPoints)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":  period,
+		"points":  points,
+		"summary": summary,
+	})
+}
+
+// GetTopItemsReport ranks items by revenue in paid orders
+func GetTopItemsReport(c *gin.Context) {
+	filter, ok := parseOrderFilter(c, utils.InvoiceStatuses)
+	if !ok {
+		return
+	}
+
+	items, err := utils.TopItems(utils.DB, filter, parseReportLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetTopCategoriesReport ranks categories by revenue in paid orders
+func GetTopCategoriesReport(c *gin.Context) {
+	filter, ok := parseOrderFilter(c, utils.InvoiceStatuses)
+	if !ok {
+		return
+	}
+
+	categories, err := utils.TopCategories(utils.DB, filter, parseReportLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"max=200"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending approved rejected"`
+}
+
+// ReviewResponse is a review with its author's username
+type ReviewResponse struct {
+	ID        uint      `json:"id"`
+	ItemID    uint      `json:"item_id"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Rating    int       `json:"rating"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newReviewResponse(review models.Review) ReviewResponse {
+	return ReviewResponse{
+		ID:        review.ID,
+		ItemID:    review.ItemID,
+		UserID:    review.UserID,
+		Username:  review.User.Username,
+		Rating:    review.Rating,
+		Title:     review.Title,
+		Body:      review.Body,
+		Status:    review.Status,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
+
+// reviewSorts maps the sort query parameter to an ORDER BY clause
+var reviewSorts = map[string]string{
+	"newest":  "created_at DESC, id DESC",
+	"oldest":  "created_at ASC, id ASC",
+	"highest": "rating DESC, created_at DESC",
+	"lowest":  "rating ASC, created_at DESC",
+}
+
+func ListItemReviews(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "newest")
+	order, ok := reviewSorts[sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be newest, oldest, highest or lowest"})
+		return
+	}
+	page, pageSize := parsePagination(c)
+
+	query := utils.DB.Model(&models.Review{}).Where("item_id = ? AND status = ?", item.ID, models.ReviewApproved)
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.Review
+	if err := query.Preload("User").Order(order).
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	response := make([]ReviewResponse, len(reviews))
+	for i, review := range reviews {
+		response[i] = newReviewResponse(review)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":   response,
+		"rating":    item.Rating,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func CreateReview(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var req CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only customers who received the item can review it
+	var delivered int64
+	utils.DB.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND orders.status = ? AND order_items.item_id = ?", userID, models.OrderDelivered, item.ID).
+		Count(&delivered)
+	if delivered == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only customers with a delivered order for this item can review it"})
+		return
+	}
+
+	// A review removed by a moderator still counts, so deleting it does not
+	// let the customer post again
+	var existing int64
+	if err := utils.DB.Unscoped().Model(&models.Review{}).Where("item_id = ? AND user_id = ?", item.ID, userID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this item"})
+		return
+	}
+
+	status := models.ReviewApproved
+	if utils.GetEnvBool("REVIEWS_REQUIRE_APPROVAL", false) {
+		status = models.ReviewPending
+	}
+
+	review := models.Review{
+		ItemID: item.ID,
+		UserID: userID,
+		Rating: req.Rating,
+		Title:  req.Title,
+		Body:   req.Body,
+		Status: status,
+	}
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		return utils.RecomputeItemRating(tx, item.ID)
+	})
+	// A parallel request posted a review first
+	if utils.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this item"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		return
+	}
+
+	utils.DB.Preload("User").First(&review, review.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Review submitted successfully",
+		"review":  newReviewResponse(review),
+	})
+}
+
+func ListReviewsForModeration(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	query := utils.DB.Model(&models.Review{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var reviews []models.Review
+	if err := query.Preload("User").Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	response := make([]ReviewResponse, len(reviews))
+	for i, review := range reviews {
+		response[i] = newReviewResponse(review)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":   response,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func ModerateReview(c *gin.Context) {
+	review, ok := findReviewParam(c)
+	if !ok {
+		return
+	}
+
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&review).Update("status", req.Status).Error; err != nil {
+			return err
+		}
+		return utils.RecomputeItemRating(tx, review.ItemID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+		return
+	}
+
+	utils.DB.Preload("User").First(&review, review.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review updated successfully",
+		"review":  newReviewResponse(review),
+	})
+}
+
+func DeleteReview(c *gin.Context) {
+	review, ok := findReviewParam(c)
+	if !ok {
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&review).Error; err != nil {
+			return err
+		}
+		return utils.RecomputeItemRating(tx, review.ItemID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete review"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// findReviewParam loads the review named by the :id parameter, writing the
+// error response itself when it cannot
+func findReviewParam(c *gin.Context) (models.Review, bool) {
+	var review models.Review
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return review, false
+	}
+
+	if err := utils.DB.First(&review, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return review, false
+	}
+	return review, true
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/search"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
+// SearchResult is a matching item and its relevance score
+type SearchResult struct {
+	Item  models.Item `json:"item"`
+	Score float64     `json:"score"`
+}
+
+// reindexItems refreshes items in the search index. Failures are only
+// logged because the database change has already been made.
+func reindexItems(ids ...uint) {
+	if err := utils.IndexItems(utils.DB, ids...); err != nil {
+		log.Println("Failed to update search index:", err)
+	}
+}
+
+func SearchItems(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	page, pageSize := parsePagination(c)
+
+	// Visibility is kept in the index, so only the requested page of the
+	// ranking has to be loaded
+	now := time.Now()
+	var hits []search.Result
+	if canSeeUnpublished(c) {
+		hits = utils.SearchIndex.Search(query)
+	} else {
+		hits = utils.SearchIndex.SearchVisible(query, now)
+	}
+	total := len(hits)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	hits = hits[start:end]
+
+	results := []SearchResult{}
+	if len(hits) > 0 {
+		ids := make([]uint, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+		// The index may lag behind changes made outside the API
+		itemQuery := utils.DB.Where("id IN ?", ids)
+		if !canSeeUnpublished(c) {
+			itemQuery = itemQuery.Scopes(utils.PublishedItems(now))
+		}
+		var items []models.Item
+		if err := itemQuery.Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search items"})
+			return
+		}
+		byID := map[uint]models.Item{}
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+		for _, hit := range hits {
+			if item, ok := byID[hit.ID]; ok {
+				results = append(results, SearchResult{Item: item, Score: hit.Score})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":     query,
+		"items":     results,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func SuggestSearch(c *gin.Context) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": utils.SearchIndex.Suggest(c.Query("q"), limit)})
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// errTOTPStepUsed is returned when another request used the same or a later
+// TOTP step first
+var errTOTPStepUsed = errors.New("TOTP step already used")
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type RequireTwoFactorRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+func EnrollTwoFactor(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	// The secret stays pending until confirmed with a valid code
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := utils.DB.Model(&user).Updates(map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	issuer := utils.GetEnv("TWO_FACTOR_ISSUER", "Shopping Cart")
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(issuer, user.Username, secret),
+	})
+}
+
+func ConfirmTwoFactor(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(user.TwoFactorSecret, req.Code, time.Now(), user.TwoFactorLastStep)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	var codes []string
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if !claimTOTPStep(tx, user.ID, step) {
+			return errTOTPStepUsed
+		}
+		if err := tx.Model(&user).Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errTOTPStepUsed) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if utils.TwoFactorRequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for this account"})
+		return
+	}
+	if !verifySecondFactor(&user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled":   false,
+			"two_factor_secret":    "",
+			"two_factor_last_step": 0,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !verifySecondFactor(&user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(utils.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := utils.ValidateToken(req.ChallengeToken)
+	if err != nil || claims.Purpose != utils.TokenPurposeTwoFactor {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.TokenVersion || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	// Failed codes count towards the same lockout as failed passwords
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		c.Header("Retry-After", utils.RetryAfterSeconds(time.Until(*user.LockedUntil)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked due to failed login attempts"})
+		return
+	}
+
+	if !verifySecondFactor(&user, req.Code) {
+		recordFailedLogin(c, &user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	issueSession(c, &user)
+}
+
+func SetTwoFactorRequired(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req RequireTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := utils.DB.Model(&user).Update("two_factor_required", *req.Required).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor requirement updated",
+		"user":    newUserResponse(user),
+	})
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, consuming whichever one matched
+func verifySecondFactor(user *models.User, code string) bool {
+	if step, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now(), user.TwoFactorLastStep); ok {
+		if !claimTOTPStep(utils.DB, user.ID, step) {
+			return false
+		}
+		user.TwoFactorLastStep = step
+		return true
+	}
+
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	result := utils.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalized)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// claimTOTPStep records step as the user's last used TOTP step. It fails
+// if a parallel request already used this step or a later one, so a code
+// can't be replayed.
+func claimTOTPStep(tx *gorm.DB, userID uint, step int64) bool {
+	result := tx.Model(&models.User{}).Where("id = ? AND two_factor_last_step < ?", userID, step).
+		Update("two_factor_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and issues a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(buf)
+
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(raw)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
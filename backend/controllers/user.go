@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"shopping-cart/models"
 	"shopping-cart/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type LoginRequest struct {
@@ -17,6 +20,7 @@ type LoginRequest struct {
 type SignupRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"`
 }
 
 func Signup(c *gin.Context) {
@@ -33,6 +37,20 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	// Check if email is already in use
+	if req.Email != "" {
+		if err := utils.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+	}
+
+	// Enforce password strength rules
+	if err := utils.ValidatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -44,6 +62,7 @@ func Signup(c *gin.Context) {
 	user := models.User{
 		Username: req.Username,
 		Password: string(hashedPassword),
+		Email:    req.Email,
 	}
 
 	if err := utils.DB.Create(&user).Error; err != nil {
@@ -51,6 +70,13 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	// Ask the user to confirm their email address
+	if user.Email != "" {
+		if err := sendVerificationEmail(&user); err != nil {
+			log.Println("Failed to send verification email:", err)
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "User created successfully",
 		"user_id": user.ID,
@@ -71,22 +97,65 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse to check the password while the account is locked
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		c.Header("Retry-After", utils.RetryAfterSeconds(time.Until(*user.LockedUntil)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked due to failed login attempts"})
+		return
+	}
+
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		recordFailedLogin(c, &user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
+	completeLogin(c, &user)
+}
+
+// completeLogin finishes a login once the first factor has been verified.
+// With 2FA enabled it only hands out a short-lived challenge.
+func completeLogin(c *gin.Context, user *models.User) {
+	if user.TwoFactorEnabled {
+		challengeToken, err := utils.GenerateChallengeToken(user.ID, user.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"challenge_token":     challengeToken,
+		})
+		return
+	}
+
+	issueSession(c, user)
+}
+
+// issueSession generates a JWT for a fully authenticated user, stores it
+// and writes the login response
+func issueSession(c *gin.Context, user *models.User) {
 	// Generate token
-	token, err := utils.GenerateToken(user.ID)
+	token, err := utils.GenerateToken(user.ID, user.TokenVersion)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Update user's token (single session)
+	// Update user's token (single session) and clear failed attempts. Only
+	// these columns are written so nothing else read before the password
+	// check is written back stale.
 	user.Token = token
-	utils.DB.Save(&user)
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+	utils.DB.Model(user).Updates(map[string]interface{}{
+		"token":                 token,
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Login successful",
@@ -102,10 +171,45 @@ func ListUsers(c *gin.Context) {
 		return
 	}
 
-	// Don't return passwords
-	for i := range users {
-		users[i].Password = ""
+	// Any signed in user may list accounts, so only public fields are returned
+	response := make([]PublicUserResponse, len(users))
+	for i, user := range users {
+		response[i] = newPublicUserResponse(user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": response})
+}
+
+// recordFailedLogin counts a failed password check and locks the account
+// with exponential backoff once the threshold is reached. The count is
+// incremented in the database, since parallel attempts all read the user
+// before their slow password check.
+func recordFailedLogin(c *gin.Context, user *models.User) {
+	var lock time.Duration
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Select("failed_login_attempts").Scan(&user.FailedLoginAttempts).Error; err != nil {
+			return err
+		}
+
+		lock = utils.LoginLockoutDuration(user.FailedLoginAttempts)
+		if lock <= 0 {
+			return nil
+		}
+		lockedUntil := time.Now().Add(lock)
+		user.LockedUntil = &lockedUntil
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("locked_until", lockedUntil).Error
+	})
+	if err != nil {
+		log.Println("Failed to record failed login:", err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": users})
-} 
\ No newline at end of file
+	if lock > 0 {
+		c.Header("Retry-After", utils.RetryAfterSeconds(lock))
+	}
+}
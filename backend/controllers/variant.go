@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errVariantRequired = errors.New("variant required")
+	errVariantNotFound = errors.New("variant not found")
+	errOutOfStock      = errors.New("not enough stock")
+)
+
+type CreateOptionTypeRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Values   []string `json:"values" binding:"required,min=1"`
+	Position int      `json:"position"`
+}
+
+type CreateVariantRequest struct {
+	SKU            string   `json:"sku" binding:"required"`
+	Price          *float64 `json:"price"`
+	Stock          int      `json:"stock" binding:"min=0"`
+	Image          string   `json:"image"`
+	OptionValueIDs []uint   `json:"option_value_ids"`
+}
+
+type UpdateVariantRequest struct {
+	SKU   *string  `json:"sku"`
+	Price *float64 `json:"price"`
+	Stock *int     `json:"stock"`
+	Image *string  `json:"image"`
+	// ClearPrice drops the price override so the item price applies again
+	ClearPrice bool `json:"clear_price"`
+}
+
+func ListVariants(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var optionTypes []models.OptionType
+	var variants []models.Variant
+	utils.DB.Where("item_id = ?", item.ID).Order("position, id").
+		Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Find(&optionTypes)
+	utils.DB.Where("item_id = ?", item.ID).Order("id").Preload("Options").Find(&variants)
+
+	c.JSON(http.StatusOK, gin.H{"option_types": optionTypes, "variants": variants})
+}
+
+func CreateOptionType(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var req CreateOptionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	optionType := models.OptionType{
+		ItemID:   item.ID,
+		Name:     strings.TrimSpace(req.Name),
+		Position: req.Position,
+	}
+	seen := map[string]bool{}
+	for i, value := range req.Values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[strings.ToLower(value)] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Option values must be unique and non-empty"})
+			return
+		}
+		seen[strings.ToLower(value)] = true
+		optionType.Values = append(optionType.Values, models.OptionValue{Value: value, Position: i})
+	}
+
+	if err := utils.DB.Create(&optionType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create option type"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Option type created successfully",
+		"option_type": optionType,
+	})
+}
+
+func DeleteOptionType(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var optionType models.OptionType
+	if err := utils.DB.Where("id = ? AND item_id = ?", c.Param("optionId"), item.ID).First(&optionType).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Option type not found"})
+		return
+	}
+
+	// Values still used by a variant cannot go away
+	var used int64
+	utils.DB.Table("variant_option_values").
+		Joins("JOIN option_values ON option_values.id = variant_option_values.option_value_id").
+		Joins("JOIN variants ON variants.id = variant_option_values.variant_id AND variants.deleted_at IS NULL").
+		Where("option_values.option_type_id = ?", optionType.ID).
+		Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Option type is used by variants"})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("option_type_id = ?", optionType.ID).Delete(&models.OptionValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&optionType).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete option type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Option type deleted successfully"})
+}
+
+func CreateVariant(c *gin.Context) {
+	item, ok := findItemParam(c)
+	if !ok {
+		return
+	}
+
+	var req CreateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(req.SKU) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU is required"})
+		return
+	}
+	taken, err := skuTaken(req.SKU, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+		return
+	}
+
+	// Every value must belong to this item, at most one per option type
+	var values []models.OptionValue
+	if len(req.OptionValueIDs) > 0 {
+		utils.DB.Joins("JOIN option_types ON option_types.id = option_values.option_type_id").
+			Where("option_values.id IN ? AND option_types.item_id = ?", req.OptionValueIDs, item.ID).
+			Find(&values)
+	}
+	types := map[uint]bool{}
+	for _, value := range values {
+		if types[value.OptionTypeID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A variant can have only one value per option type"})
+			return
+		}
+		types[value.OptionTypeID] = true
+	}
+	if len(values) != len(req.OptionValueIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown option value for this item"})
+		return
+	}
+
+	// Two variants of an item cannot share the same combination
+	var existing []models.Variant
+	utils.DB.Where("item_id = ?", item.ID).Preload("Options").Find(&existing)
+	key := optionKey(values)
+	for _, variant := range existing {
+		if optionKey(variant.Options) == key {
+			c.JSON(http.StatusConflict, gin.H{"error": "A variant with these options already exists"})
+			return
+		}
+	}
+
+	variant := models.Variant{
+		ItemID:  item.ID,
+		SKU:     strings.TrimSpace(req.SKU),
+		Price:   req.Price,
+		Stock:   req.Stock,
+		Image:   req.Image,
+		Options: values,
+	}
+	if err := utils.DB.Create(&variant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Variant created successfully",
+		"variant": variant,
+	})
+}
+
+func UpdateVariant(c *gin.Context) {
+	variant, ok := findVariantParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.SKU != nil {
+		if strings.TrimSpace(*req.SKU) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "SKU is required"})
+			return
+		}
+		taken, err := skuTaken(*req.SKU, variant.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variant"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+		updates["sku"] = strings.TrimSpace(*req.SKU)
+	}
+	if req.ClearPrice {
+		updates["price"] = nil
+	} else if req.Price != nil {
+		updates["price"] = *req.Price
+	}
+	if req.Stock != nil {
+		if *req.Stock < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot be negative"})
+			return
+		}
+		updates["stock"] = *req.Stock
+	}
+	if req.Image != nil {
+		updates["image"] = *req.Image
+	}
+
+	if err := utils.DB.Model(&variant).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variant"})
+		return
+	}
+
+	utils.DB.Preload("Options").First(&variant, variant.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Variant updated successfully",
+		"variant": variant,
+	})
+}
+
+func DeleteVariant(c *gin.Context) {
+	variant, ok := findVariantParam(c)
+	if !ok {
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Drop the variant from carts; placed orders keep their snapshot
+		if err := tx.Where("variant_id = ?", variant.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&variant).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete variant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
+}
+
+// findItemParam loads the item named by the :id parameter, writing the
+// error response itself when it cannot. Drafts are only found by admins.
+func findItemParam(c *gin.Context) (models.Item, bool) {
+	var item models.Item
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return item, false
+	}
+
+	if err := utils.DB.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return item, false
+	}
+	if item.EffectiveStatus(time.Now()) == models.ItemDraft && !canSeeUnpublished(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return item, false
+	}
+	return item, true
+}
+
+// findVariantParam loads the variant named by :variantId that belongs to
+// the item named by :id
+func findVariantParam(c *gin.Context) (models.Variant, bool) {
+	var variant models.Variant
+	item, ok := findItemParam(c)
+	if !ok {
+		return variant, false
+	}
+
+	if err := utils.DB.Where("id = ? AND item_id = ?", c.Param("variantId"), item.ID).First(&variant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return variant, false
+	}
+	return variant, true
+}
+
+// skuTaken reports whether another variant already uses the SKU
+func skuTaken(sku string, exceptID uint) (bool, error) {
+	var count int64
+	err := utils.DB.Model(&models.Variant{}).Where("sku = ? AND id <> ?", strings.TrimSpace(sku), exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// optionKey identifies a combination of option values independent of order
+func optionKey(values []models.OptionValue) string {
+	ids := make([]int, len(values))
+	for i, value := range values {
+		ids[i] = int(value.ID)
+	}
+	sort.Ints(ids)
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// resolveCartVariant checks the variant chosen for an item. Items with
+// variants must be bought as one of them.
+func resolveCartVariant(tx *gorm.DB, item models.Item, variantID *uint) (*models.Variant, error) {
+	if variantID == nil {
+		var count int64
+		if err := tx.Model(&models.Variant{}).Where("item_id = ?", item.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errVariantRequired
+		}
+		return nil, nil
+	}
+
+	var variant models.Variant
+	err := tx.Where("id = ? AND item_id = ?", *variantID, item.ID).First(&variant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errVariantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errVerificationTokenUsed is returned when a verification token was used or
+// expired after it was looked up
+var errVerificationTokenUsed = errors.New("verification token already used")
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// getEmailVerificationTTL returns how long a verification token stays valid
+func getEmailVerificationTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("EMAIL_VERIFICATION_TTL_HOURS", 48)) * time.Hour
+}
+
+// sendVerificationEmail issues a new verification token for the user's
+// current email and mails it
+func sendVerificationEmail(user *models.User) error {
+	token, tokenHash, err := utils.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(getEmailVerificationTTL()),
+	}
+	if err := utils.DB.Create(&verification).Error; err != nil {
+		return err
+	}
+
+	verifyURL := utils.GetEnv("FRONTEND_URL", "http://localhost:3000") + "/verify-email?token=" + token
+	return utils.SendMail(utils.MailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address using the link below. It expires in %d hours.\n\n%s\n\nVerification token: %s",
+			user.Username, int(getEmailVerificationTTL().Hours()), verifyURL, token),
+	})
+}
+
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var verification models.EmailVerificationToken
+	err := utils.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(req.Token), time.Now()).
+		First(&verification).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.First(&user, verification.UserID).Error; err != nil || user.Email != verification.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the token so that concurrent requests can only use it once
+		now := time.Now()
+		result := tx.Model(&models.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", verification.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errVerificationTokenUsed
+		}
+
+		// Consume every other outstanding verification token for the user
+		if err := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		// The address may have changed since the token was looked up
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", user.ID, verification.Email).
+			Update("email_verified_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errVerificationTokenUsed
+		}
+		return nil
+	})
+	if errors.Is(err, errVerificationTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var user models.User
+	if err := utils.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No email address on file"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
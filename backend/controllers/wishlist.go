@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AddToWishlistRequest struct {
+	ItemID    uint  `json:"item_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
+}
+
+type MoveToCartRequest struct {
+	Quantity int `json:"quantity" binding:"omitempty,min=1"`
+}
+
+// WishlistItemResponse is a wishlist entry with its current price and
+// availability
+type WishlistItemResponse struct {
+	ID           uint            `json:"id"`
+	ItemID       uint            `json:"item_id"`
+	VariantID    *uint           `json:"variant_id"`
+	Item         models.Item     `json:"item"`
+	Variant      *models.Variant `json:"variant,omitempty"`
+	AddedPrice   float64         `json:"added_price"`
+	Price        float64         `json:"price"`
+	PriceDropped bool            `json:"price_dropped"`
+	InStock      bool            `json:"in_stock"`
+	AddedAt      time.Time       `json:"added_at"`
+}
+
+type WishlistResponse struct {
+	ID       uint                   `json:"id"`
+	Items    []WishlistItemResponse `json:"items"`
+	ShareURL *string                `json:"share_url,omitempty"`
+}
+
+// newWishlistResponse leaves out entries whose item has been deleted and,
+// on shared views, items that are no longer published
+func newWishlistResponse(wishlist models.Wishlist, owner bool) WishlistResponse {
+	now := time.Now()
+	response := WishlistResponse{ID: wishlist.ID, Items: []WishlistItemResponse{}}
+	for _, entry := range wishlist.Items {
+		if entry.Item.ID == 0 || (!owner && entry.Item.EffectiveStatus(now) != models.ItemActive) {
+			continue
+		}
+		price := entry.CurrentPrice()
+		response.Items = append(response.Items, WishlistItemResponse{
+			ID:           entry.ID,
+			ItemID:       entry.ItemID,
+			VariantID:    entry.VariantID,
+			Item:         entry.Item,
+			Variant:      entry.Variant,
+			AddedPrice:   entry.AddedPrice,
+			Price:        price,
+			PriceDropped: price < entry.AddedPrice,
+			InStock:      entry.Available(now),
+			AddedAt:      entry.CreatedAt,
+		})
+	}
+	if owner && wishlist.ShareToken != nil {
+		url := wishlistShareURL(*wishlist.ShareToken)
+		response.ShareURL = &url
+	}
+	return response
+}
+
+func wishlistShareURL(token string) string {
+	return utils.GetEnv("FRONTEND_URL", "http://localhost:3000") + "/wishlists/shared/" + token
+}
+
+// userWishlist returns the user's wishlist, creating an empty one on first use
+func userWishlist(userID uint) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := utils.DB.Where(models.Wishlist{UserID: userID}).FirstOrCreate(&wishlist).Error
+	if utils.IsUniqueViolation(err) {
+		// A parallel request created it first
+		wishlist = models.Wishlist{}
+		err = utils.DB.Where(models.Wishlist{UserID: userID}).First(&wishlist).Error
+	}
+	return wishlist, err
+}
+
+func preloadWishlist(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC, id DESC") }).
+		Preload("Items.Item").Preload("Items.Variant.Options")
+}
+
+func GetWishlist(c *gin.Context) {
+	wishlist, err := userWishlist(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+		return
+	}
+	if err := preloadWishlist(utils.DB).First(&wishlist, wishlist.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"wishlist": newWishlistResponse(wishlist, true)})
+}
+
+func AddToWishlist(c *gin.Context) {
+	var req AddToWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.Item
+	if err := utils.DB.First(&item, req.ItemID).Error; err != nil || item.EffectiveStatus(time.Now()) == models.ItemDraft {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	// Entries name what would be bought, like cart lines do
+	variant, err := resolveCartVariant(utils.DB, item, req.VariantID)
+	switch {
+	case errors.Is(err, errVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "variant_id is required for this item"})
+		return
+	case errors.Is(err, errVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to wishlist"})
+		return
+	}
+
+	wishlist, err := userWishlist(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to wishlist"})
+		return
+	}
+
+	var existing int64
+	whereVariant(utils.DB.Model(&models.WishlistItem{}).Where("wishlist_id = ? AND item_id = ?", wishlist.ID, item.ID), req.VariantID).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item is already on your wishlist"})
+		return
+	}
+
+	entry := models.WishlistItem{WishlistID: wishlist.ID, ItemID: item.ID, Item: item, VariantID: req.VariantID, Variant: variant}
+	entry.AddedPrice = entry.CurrentPrice()
+	entry.LastPrice = entry.AddedPrice
+	entry.LastInStock = entry.Available(time.Now())
+	if err := utils.DB.Omit("Item", "Variant").Create(&entry).Error; err != nil {
+		// A parallel add of the same item got there first
+		if utils.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Item is already on your wishlist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Item added to wishlist successfully",
+		"item":    newWishlistResponse(models.Wishlist{ID: wishlist.ID, Items: []models.WishlistItem{entry}}, true).Items[0],
+	})
+}
+
+// findWishlistEntry loads the caller's wishlist entry named by :id
+func findWishlistEntry(c *gin.Context) (models.WishlistItem, bool) {
+	var entry models.WishlistItem
+	err := utils.DB.Joins("JOIN wishlists ON wishlists.id = wishlist_items.wishlist_id").
+		Where("wishlist_items.id = ? AND wishlists.user_id = ?", c.Param("id"), c.GetUint("user_id")).
+		First(&entry).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist item not found"})
+		return entry, false
+	}
+	return entry, true
+}
+
+func RemoveFromWishlist(c *gin.Context) {
+	entry, ok := findWishlistEntry(c)
+	if !ok {
+		return
+	}
+
+	if err := utils.DB.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item removed from wishlist successfully"})
+}
+
+// MoveWishlistItemToCart adds the entry to the active cart and removes it
+// from the wishlist. The entry stays if it cannot be added.
+func MoveWishlistItemToCart(c *gin.Context) {
+	var req MoveToCartRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	entry, ok := findWishlistEntry(c)
+	if !ok {
+		return
+	}
+
+	cartReq := AddToCartRequest{ItemID: entry.ItemID, VariantID: entry.VariantID, Quantity: req.Quantity}
+	cart, ok := addToCart(c, c.GetUint("user_id"), cartReq, 0, false)
+	if !ok {
+		return
+	}
+
+	if err := utils.DB.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from wishlist"})
+		return
+	}
+
+	c.Header("ETag", utils.FormatETag(cart.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Item moved to cart successfully"})
+}
+
+// ShareWishlist turns on the read-only share link, keeping an existing one
+func ShareWishlist(c *gin.Context) {
+	wishlist, err := userWishlist(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share wishlist"})
+		return
+	}
+
+	if wishlist.ShareToken == nil {
+		token, _, err := utils.GenerateRandomToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share wishlist"})
+			return
+		}
+		if err := utils.DB.Model(&wishlist).Update("share_token", token).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share wishlist"})
+			return
+		}
+		wishlist.ShareToken = &token
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"share_token": *wishlist.ShareToken,
+		"share_url":   wishlistShareURL(*wishlist.ShareToken),
+	})
+}
+
+// UnshareWishlist revokes the share link; sharing again creates a new one
+func UnshareWishlist(c *gin.Context) {
+	if err := utils.DB.Model(&models.Wishlist{}).Where("user_id = ?", c.GetUint("user_id")).
+		Update("share_token", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop sharing wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist is no longer shared"})
+}
+
+// GetSharedWishlist is the public read-only view of a shared wishlist.
+// Wishlists of deleted accounts are never shown.
+func GetSharedWishlist(c *gin.Context) {
+	var wishlist models.Wishlist
+	err := preloadWishlist(utils.DB).
+		Joins("JOIN users ON users.id = wishlists.user_id AND users.deleted_at IS NULL").
+		Where("wishlists.share_token = ?", c.Param("token")).
+		First(&wishlist).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist not found"})
+		return
+	}
+
+	var owner models.User
+	utils.DB.Select("username", "display_name").First(&owner, wishlist.UserID)
+	name := owner.DisplayName
+	if name == "" {
+		name = owner.Username
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"owner":    name,
+		"wishlist": newWishlistResponse(wishlist, false),
+	})
+}
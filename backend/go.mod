@@ -11,6 +11,7 @@ require (
 	github.com/onsi/gomega v1.38.0
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.40.0
+	golang.org/x/image v0.29.0
 	gorm.io/gorm v1.30.1
 )
 
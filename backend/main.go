@@ -5,6 +5,7 @@ import (
 	"os"
 	"shopping-cart/routes"
 	"shopping-cart/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -19,11 +20,76 @@ func main() {
 	// Initialize database
 	utils.InitDB()
 
+	// Run a catalog command instead of the server when one is given
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// Initialize outgoing mail
+	utils.InitMailer()
+
+	// Initialize file storage for uploads
+	utils.InitStorage()
+
+	// Build the in-memory search index
+	if err := utils.BuildSearchIndex(utils.DB); err != nil {
+		log.Fatal("Failed to build search index:", err)
+	}
+
+	// Apply scheduled item publishing in the background
+	utils.StartJob("item schedule", utils.GetEnvSeconds("ITEM_SCHEDULE_INTERVAL_SECONDS", time.Minute), func(now time.Time) error {
+		ids, err := utils.ApplyItemSchedules(utils.DB, now)
+		if err != nil {
+			return err
+		}
+		return utils.IndexItems(utils.DB, ids...)
+	})
+	utils.StartJob("price schedule", utils.GetEnvSeconds("PRICE_SCHEDULE_INTERVAL_SECONDS", time.Minute), func(now time.Time) error {
+		return utils.ApplyScheduledPrices(utils.DB, now)
+	})
+	utils.StartJob("wishlist alerts", utils.GetEnvSeconds("WISHLIST_ALERT_INTERVAL_SECONDS", 5*time.Minute), func(now time.Time) error {
+		return utils.NotifyWishlistChanges(utils.DB, now)
+	})
+
+	utils.StartJob("guest views cleanup", utils.GetEnvSeconds("GUEST_VIEW_CLEANUP_INTERVAL_SECONDS", time.Hour), func(now time.Time) error {
+		return utils.CleanupGuestViews(utils.DB, now)
+	})
+
+	// Reindexing also picks up changes made outside this process, such as CLI imports
+	utils.StartJob("search index", utils.GetEnvSeconds("SEARCH_REINDEX_INTERVAL_SECONDS", 10*time.Minute), func(now time.Time) error {
+		return utils.BuildSearchIndex(utils.DB)
+	})
+
+	// Recommendations are also built at startup so they don't wait for the first run
+	go func() {
+		if err := utils.RebuildRecommendations(utils.DB, time.Now()); err != nil {
+			log.Printf("Failed to build recommendations: %v", err)
+		}
+	}()
+	utils.StartJob("recommendations", utils.GetEnvSeconds("RECOMMENDATIONS_INTERVAL_SECONDS", time.Hour), func(now time.Time) error {
+		return utils.RebuildRecommendations(utils.DB, now)
+	})
+
+	// Metric rollups are also refreshed at startup, which backfills a new database
+	go func() {
+		if err := utils.RollupMetrics(utils.DB, time.Now()); err != nil {
+			log.Printf("Failed to roll up metrics: %v", err)
+		}
+	}()
+	utils.StartJob("metrics rollup", utils.GetEnvSeconds("METRICS_ROLLUP_INTERVAL_SECONDS", 5*time.Minute), func(now time.Time) error {
+		return utils.RollupMetrics(utils.DB, now)
+	})
+
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Refuse to start with broken keys or a default secret in release mode
+	if err := utils.ValidateJWTConfig(gin.Mode() == gin.ReleaseMode); err != nil {
+		log.Fatal("Invalid JWT configuration: ", err)
+	}
+
 	// Create Gin router
 	r := gin.Default()
 
@@ -35,8 +101,9 @@ func main() {
 		}
 		
 		c.Header("Access-Control-Allow-Origin", corsOrigin)
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, If-Match, X-API-Key, X-Guest-Token")
+		c.Header("Access-Control-Expose-Headers", "ETag, Retry-After, X-Guest-Token")
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+	"shopping-cart/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets admin users through. It must run after
+// AuthMiddleware, which puts the user's role in the context.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
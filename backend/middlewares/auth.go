@@ -2,38 +2,128 @@ package middlewares
 
 import (
 	"net/http"
+	"shopping-cart/models"
 	"shopping-cart/utils"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// twoFactorEnrollmentPaths stay reachable for accounts that are required to
+// enable two-factor authentication but have not done so yet
+var twoFactorEnrollmentPaths = map[string]bool{
+	"/users/me":             true,
+	"/users/me/2fa/enroll":  true,
+	"/users/me/2fa/confirm": true,
+}
+
+// apiKeyLastUsedResolution limits how often last_used_at is written
+const apiKeyLastUsedResolution = time.Minute
+
+// authFailure is why a request could not be authenticated, as the
+// response AuthMiddleware sends
+type authFailure struct {
+	status  int
+	message string
+}
+
+func unauthorized(message string) *authFailure {
+	return &authFailure{status: http.StatusUnauthorized, message: message}
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		user, apiKey, failure := authenticate(c)
+		if failure != nil {
+			c.JSON(failure.status, gin.H{"error": failure.message})
 			c.Abort()
 			return
 		}
+		setCaller(c, user, apiKey)
+		c.Next()
+	}
+}
 
-		// Check if it's Bearer token
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
+// OptionalAuthMiddleware identifies the caller on public routes that show
+// more to admins. Missing or invalid credentials leave the request anonymous.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, apiKey, failure := authenticate(c); failure == nil {
+			setCaller(c, user, apiKey)
 		}
+		c.Next()
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := utils.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+// authenticate resolves the caller from an X-API-Key header or a bearer
+// token. The API key is nil for bearer tokens.
+func authenticate(c *gin.Context) (models.User, *models.APIKey, *authFailure) {
+	// Service-to-service requests authenticate with an API key instead
+	if rawKey := c.GetHeader("X-API-Key"); rawKey != "" {
+		return authenticateAPIKey(rawKey)
+	}
 
-		// Set user ID in context
-		c.Set("user_id", claims.UserID)
-		c.Next()
+	var user models.User
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return user, nil, unauthorized("Authorization header required")
+	}
+
+	// Check if it's Bearer token
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return user, nil, unauthorized("Invalid authorization header format")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil || claims.Purpose != "" {
+		return user, nil, unauthorized("Invalid token")
+	}
+
+	// Reject tokens for deleted users or revoked sessions
+	if err := utils.DB.Select("id", "role", "token_version", "two_factor_enabled", "two_factor_required").
+		First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.TokenVersion {
+		return user, nil, unauthorized("Invalid token")
+	}
+
+	// Accounts that must use 2FA can only reach enrollment until they do
+	if utils.TwoFactorRequired(user) && !user.TwoFactorEnabled && !twoFactorEnrollmentPaths[c.FullPath()] {
+		return user, nil, &authFailure{status: http.StatusForbidden, message: "Two-factor authentication must be enabled for this account"}
+	}
+	return user, nil, nil
+}
+
+// authenticateAPIKey resolves an X-API-Key header to the user the key acts as
+func authenticateAPIKey(rawKey string) (models.User, *models.APIKey, *authFailure) {
+	var user models.User
+	var apiKey models.APIKey
+	if err := utils.DB.Where("key_hash = ? AND revoked_at IS NULL", utils.HashToken(rawKey)).First(&apiKey).Error; err != nil {
+		return user, nil, unauthorized("Invalid API key")
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return user, nil, unauthorized("API key has expired")
+	}
+
+	if err := utils.DB.Select("id", "role").First(&user, apiKey.UserID).Error; err != nil {
+		return user, nil, unauthorized("Invalid API key")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyLastUsedResolution {
+		utils.DB.Model(&apiKey).UpdateColumn("last_used_at", now)
+	}
+	return user, &apiKey, nil
+}
+
+// setCaller stores the authenticated user in the context, along with the
+// API key's scopes for RequireScope
+func setCaller(c *gin.Context, user models.User, apiKey *models.APIKey) {
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	if apiKey != nil {
+		c.Set("api_key_id", apiKey.ID)
+		c.Set("api_key_scopes", apiKey.ScopeList())
 	}
-} 
\ No newline at end of file
+}
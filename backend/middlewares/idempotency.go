@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength bounds the header value stored per request
+const maxIdempotencyKeyLength = 255
+
+// getIdempotencyTTL returns how long stored responses are replayed
+func getIdempotencyTTL() time.Duration {
+	hoursStr := os.Getenv("IDEMPOTENCY_KEY_TTL_HOURS")
+	if hoursStr == "" {
+		return 24 * time.Hour // default 24 hours
+	}
+
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil || hours <= 0 {
+		return 24 * time.Hour // fallback to 24 hours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// responseRecorder captures the response body so it can be stored
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header per user. The first
+// request for a key is executed and its response stored; retries with the same
+// body replay that response, and retries with a different body are rejected.
+// It must run after AuthMiddleware.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key header is too long"})
+			c.Abort()
+			return
+		}
+
+		userID := c.GetUint("user_id")
+
+		// Read the body so it can be fingerprinted and handed on to the handler
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		// Drop an expired record for this key so it can be reused
+		utils.DB.Where("user_id = ? AND idempotency_key = ? AND created_at < ?", userID, key, time.Now().Add(-getIdempotencyTTL())).
+			Delete(&models.IdempotencyKey{})
+
+		var existing models.IdempotencyKey
+		if err := utils.DB.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error; err == nil {
+			replayIdempotentResponse(c, &existing, fingerprint)
+			return
+		}
+
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.FullPath(),
+			Fingerprint: fingerprint,
+		}
+		if err := utils.DB.Create(&record).Error; err != nil {
+			// Another request with the same key won the race
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+			c.Abort()
+			return
+		}
+
+		// A handler that panics must not leave the key in progress forever
+		defer func() {
+			if r := recover(); r != nil {
+				utils.DB.Delete(&record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			// Server errors are not stored so the client can retry
+			utils.DB.Delete(&record)
+			return
+		}
+
+		utils.DB.Model(&record).Updates(map[string]interface{}{
+			"status_code":   status,
+			"content_type":  c.Writer.Header().Get("Content-Type"),
+			"response_body": recorder.body.Bytes(),
+		})
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, record *models.IdempotencyKey, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		c.Abort()
+		return
+	}
+
+	if record.StatusCode == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+		c.Abort()
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+	c.Abort()
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"shopping-cart/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByIP throttles requests per client IP
+func RateLimitByIP(limiter *utils.RateLimiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// RateLimitByUser throttles requests per authenticated user, falling back to
+// the client IP. It must run after AuthMiddleware.
+func RateLimitByUser(limiter *utils.RateLimiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		if userID := c.GetUint("user_id"); userID != 0 {
+			return "user:" + strconv.FormatUint(uint64(userID), 10)
+		}
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func rateLimit(limiter *utils.RateLimiter, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.GetEnvBool("RATE_LIMIT_ENABLED", true) {
+			c.Next()
+			return
+		}
+
+		allowed, wait := limiter.Allow(keyFunc(c))
+		if !allowed {
+			c.Header("Retry-After", utils.RetryAfterSeconds(wait))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please slow down"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
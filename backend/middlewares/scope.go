@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope limits API key requests to keys granted the scope. Requests
+// authenticated with a user session are not restricted by scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get("api_key_scopes"); ok {
+			granted := false
+			for _, s := range scopes.([]string) {
+				if s == scope {
+					granted = true
+					break
+				}
+			}
+			if !granted {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects API keys on routes that manage the account's own
+// credentials and must only be reachable by the user themselves
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_scopes"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
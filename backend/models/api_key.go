@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyScopes lists every scope an API key can be granted
+var APIKeyScopes = []string{
+	"users:read",
+	"users:write",
+	"items:write",
+	"carts:read",
+	"carts:write",
+	"orders:read",
+	"orders:write",
+	"admin",
+}
+
+// APIKey lets a backend service act as a user without a JWT. Only the
+// SHA-256 hash of the key is stored; the prefix identifies it in listings.
+type APIKey struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name" gorm:"not null"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"-" gorm:"foreignKey:UserID"`
+	Prefix      string     `json:"prefix" gorm:"not null"`
+	KeyHash     string     `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes      string     `json:"-" gorm:"not null"`
+	CreatedByID uint       `json:"created_by_id"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ScopeList returns the key's scopes as a slice
+func (k APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key was granted the scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
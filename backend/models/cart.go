@@ -13,16 +13,28 @@ type Cart struct {
 	Name      string         `json:"name"`
 	Status    string         `json:"status" gorm:"default:'active'"`
 	Items     []CartItem     `json:"items" gorm:"foreignKey:CartID"`
+	Version   uint           `json:"version" gorm:"not null;default:1"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 type CartItem struct {
-	ID       uint    `json:"id" gorm:"primaryKey"`
-	CartID   uint    `json:"cart_id" gorm:"not null"`
-	ItemID   uint    `json:"item_id" gorm:"not null"`
-	Item     Item    `json:"item" gorm:"foreignKey:ItemID"`
-	Quantity int     `json:"quantity" gorm:"not null;default:1"`
-	Price    float64 `json:"price" gorm:"not null"`
-} 
\ No newline at end of file
+	ID        uint     `json:"id" gorm:"primaryKey"`
+	CartID    uint     `json:"cart_id" gorm:"not null"`
+	ItemID    uint     `json:"item_id" gorm:"not null"`
+	Item      Item     `json:"item" gorm:"foreignKey:ItemID"`
+	VariantID *uint    `json:"variant_id" gorm:"index"`
+	Variant   *Variant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	Quantity  int      `json:"quantity" gorm:"not null;default:1"`
+	Price     float64  `json:"price" gorm:"not null"`
+}
+
+// CurrentPrice returns what the line costs now, which may differ from the
+// Price snapshot taken when it was added. Item and Variant must be loaded.
+func (c CartItem) CurrentPrice() float64 {
+	if c.Variant != nil {
+		return c.Variant.EffectivePrice(c.Item)
+	}
+	return c.Item.Price
+}
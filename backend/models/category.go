@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Category groups items into a tree. Items keep the category name in
+// Item.Category for display; CategoryID is the authoritative link.
+type Category struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"not null;uniqueIndex"`
+	ParentID  *uint     `json:"parent_id" gorm:"index"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
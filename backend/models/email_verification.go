@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailVerificationToken confirms that a user controls an email address.
+// The address is stored so a token becomes useless once the email changes.
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Email     string     `json:"email" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
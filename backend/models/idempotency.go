@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey records the outcome of a mutating request sent with an
+// Idempotency-Key header so that client retries can be replayed safely.
+type IdempotencyKey struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;not null;uniqueIndex:idx_idempotency_user_key"`
+	Method       string    `json:"method" gorm:"not null"`
+	Path         string    `json:"path" gorm:"not null"`
+	Fingerprint  string    `json:"fingerprint" gorm:"not null"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type"`
+	ResponseBody []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
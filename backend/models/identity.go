@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's subject claim
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"subject" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OIDCLoginState keeps the PKCE verifier and nonce of an authorization
+// request until the provider redirects back with the matching state
+type OIDCLoginState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"not null;uniqueIndex"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
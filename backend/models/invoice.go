@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Invoice is issued once an order is paid. Sequence numbers are assigned
+// in the same transaction as the payment, so they have no gaps. Seller,
+// customer and tax details are copied at issue time so later configuration
+// or profile changes don't alter an issued invoice.
+type Invoice struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	OrderID         uint      `json:"order_id" gorm:"not null;uniqueIndex"`
+	Sequence        uint      `json:"sequence" gorm:"not null;uniqueIndex"`
+	Number          string    `json:"number" gorm:"not null;uniqueIndex"`
+	IssuedAt        time.Time `json:"issued_at" gorm:"not null"`
+	Currency        string    `json:"currency" gorm:"not null"`
+	TaxName         string    `json:"tax_name"`
+	TaxRate         float64   `json:"tax_rate" gorm:"not null;default:0"`
+	Subtotal        float64   `json:"subtotal" gorm:"not null"`
+	TaxAmount       float64   `json:"tax_amount" gorm:"not null"`
+	Total           float64   `json:"total" gorm:"not null"`
+	SellerName      string    `json:"seller_name"`
+	SellerAddress   string    `json:"seller_address"`
+	SellerTaxID     string    `json:"seller_tax_id"`
+	SellerEmail     string    `json:"seller_email"`
+	CustomerName    string    `json:"customer_name"`
+	CustomerEmail   string    `json:"customer_email"`
+	BillingAddress  string    `json:"billing_address"`
+	ShippingAddress string    `json:"shipping_address"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -6,18 +6,48 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	ItemDraft    = "draft"
+	ItemActive   = "active"
+	ItemArchived = "archived"
+)
+
 type Item struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
+	SKU         string         `json:"sku" gorm:"index"`
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
 	Price       float64        `json:"price" gorm:"not null"`
 	Category    string         `json:"category"`
+	CategoryID  *uint          `json:"category_id" gorm:"index"`
 	Rating      float64        `json:"rating"`
 	Reviews     int            `json:"reviews"`
 	Image       string         `json:"image"`
 	InStock     bool           `json:"in_stock" gorm:"default:true"`
 	Status      string         `json:"status" gorm:"default:'active'"`
+	PublishAt   *time.Time     `json:"publish_at"`
+	UnpublishAt *time.Time     `json:"unpublish_at"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
+	OptionTypes []OptionType   `json:"option_types,omitempty" gorm:"foreignKey:ItemID"`
+	Variants    []Variant      `json:"variants,omitempty" gorm:"foreignKey:ItemID"`
+	Images      []ItemImage    `json:"images,omitempty" gorm:"foreignKey:ItemID"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
-} 
\ No newline at end of file
+}
+
+// EffectiveStatus applies the publish schedule to the stored status. A
+// draft or active item waits for PublishAt and any item is archived from
+// UnpublishAt on.
+func (i Item) EffectiveStatus(now time.Time) string {
+	if i.Status == ItemArchived || (i.UnpublishAt != nil && !now.Before(*i.UnpublishAt)) {
+		return ItemArchived
+	}
+	if i.PublishAt != nil {
+		if now.Before(*i.PublishAt) {
+			return ItemDraft
+		}
+		return ItemActive
+	}
+	return i.Status
+}
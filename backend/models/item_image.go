@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ItemImage is an uploaded image of an item. Images are shown in Position
+// order and the first one becomes Item.Image.
+type ItemImage struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	ItemID      uint            `json:"item_id" gorm:"not null;index"`
+	Position    int             `json:"position" gorm:"not null;default:0"`
+	URL         string          `json:"url" gorm:"not null"`
+	ContentType string          `json:"content_type"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Size        int64           `json:"size"`
+	Thumbnails  ImageThumbnails `json:"thumbnails"`
+	// StorageKeys lists every stored file of the image, comma separated
+	StorageKeys string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ImageThumbnails maps a thumbnail size in pixels to its URL, stored as a
+// JSON column
+type ImageThumbnails map[string]string
+
+func (t ImageThumbnails) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (t *ImageThumbnails) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return errors.New("unsupported type for image thumbnails")
+	}
+	return json.Unmarshal(data, t)
+}
+
+func (ImageThumbnails) GormDataType() string {
+	return "text"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// ItemView is an entry in a viewer's recently viewed list. A viewer is a
+// user or a guest, identified by the hash of their guest token.
+type ItemView struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         *uint     `json:"user_id" gorm:"index"`
+	GuestTokenHash string    `json:"-" gorm:"index"`
+	ItemID         uint      `json:"item_id" gorm:"not null;index"`
+	Item           Item      `json:"item" gorm:"foreignKey:ItemID"`
+	ViewedAt       time.Time `json:"viewed_at" gorm:"not null;index"`
+}
+
+// GuestToken is a guest token handed out by the server. Only views sent
+// with a known token are recorded, and tokens unused for the guest view
+// TTL are removed along with their history.
+type GuestToken struct {
+	TokenHash  string    `json:"-" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" gorm:"not null;index"`
+}
+
+// ItemViewCount counts the views of an item on one UTC day
+type ItemViewCount struct {
+	ItemID uint      `json:"item_id" gorm:"primaryKey;autoIncrement:false"`
+	Day    time.Time `json:"day" gorm:"primaryKey"`
+	Views  int64     `json:"views" gorm:"not null;default:0"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// CartSession is one trip through the cart. It starts when an item is
+// added to a cart without an open session and ends when the cart becomes
+// an order. Carts are reused after checkout, so sessions rather than
+// carts count how often shoppers start a cart.
+type CartSession struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CartID    uint      `json:"cart_id" gorm:"not null;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	StartedAt time.Time `json:"started_at" gorm:"not null;index"`
+	OrderID   *uint     `json:"order_id" gorm:"index"`
+}
+
+// MetricCounts are the dashboard figures of one period. Orders counts
+// every order placed and Revenue only those that were paid. CartsCreated
+// counts cart sessions started, of which CartsConverted became orders.
+type MetricCounts struct {
+	Orders         int64   `json:"orders" gorm:"not null;default:0"`
+	Revenue        float64 `json:"revenue" gorm:"not null;default:0"`
+	Signups        int64   `json:"signups" gorm:"not null;default:0"`
+	CartsCreated   int64   `json:"carts_created" gorm:"not null;default:0"`
+	CartsConverted int64   `json:"carts_converted" gorm:"not null;default:0"`
+}
+
+// HourlyMetric rolls up one UTC hour
+type HourlyMetric struct {
+	Hour time.Time `json:"hour" gorm:"primaryKey"`
+	MetricCounts
+}
+
+// DailyMetric rolls up one UTC day. Weeks and months are summed from days.
+type DailyMetric struct {
+	Day time.Time `json:"day" gorm:"primaryKey"`
+	MetricCounts
+}
@@ -6,14 +6,28 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
 type Order struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CartID    uint           `json:"cart_id" gorm:"not null"`
 	Cart      Cart           `json:"cart" gorm:"foreignKey:CartID"`
 	UserID    uint           `json:"user_id" gorm:"not null"`
 	User      User           `json:"user" gorm:"foreignKey:UserID"`
+	Items     []OrderItem    `json:"items" gorm:"foreignKey:OrderID"`
 	Total     float64        `json:"total" gorm:"not null"`
 	Status    string         `json:"status" gorm:"default:'pending'"`
+
+	BillingAddress  string   `json:"billing_address"`
+	ShippingAddress string   `json:"shipping_address"`
+	Invoice         *Invoice `json:"invoice,omitempty" gorm:"foreignKey:OrderID"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
@@ -0,0 +1,15 @@
+package models
+
+// OrderItem is a line of an order. Name, SKU and Price are copied from the
+// item and variant when the order is placed so later edits don't change it.
+type OrderItem struct {
+	ID        uint     `json:"id" gorm:"primaryKey"`
+	OrderID   uint     `json:"order_id" gorm:"not null;index"`
+	ItemID    uint     `json:"item_id" gorm:"not null;index"`
+	VariantID *uint    `json:"variant_id" gorm:"index"`
+	Variant   *Variant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	Name      string   `json:"name"`
+	SKU       string   `json:"sku"`
+	Quantity  int      `json:"quantity" gorm:"not null"`
+	Price     float64  `json:"price" gorm:"not null"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// Reasons recorded with a price change
+const (
+	PriceReasonInitial   = "initial"
+	PriceReasonManual    = "manual"
+	PriceReasonImport    = "import"
+	PriceReasonScheduled = "scheduled"
+	PriceReasonSaleStart = "sale_start"
+	PriceReasonSaleEnd   = "sale_end"
+)
+
+// PriceHistory is one period during which an item had a price. The current
+// price has no EffectiveTo.
+type PriceHistory struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ItemID        uint       `json:"item_id" gorm:"not null;index"`
+	Price         float64    `json:"price" gorm:"not null"`
+	PreviousPrice *float64   `json:"previous_price"`
+	Reason        string     `json:"reason" gorm:"not null"`
+	ChangedByID   *uint      `json:"changed_by_id"`
+	EffectiveFrom time.Time  `json:"effective_from" gorm:"not null;index"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+const (
+	PriceSchedulePending   = "pending"
+	PriceScheduleActive    = "active"
+	PriceScheduleCompleted = "completed"
+	PriceScheduleCancelled = "cancelled"
+)
+
+// ScheduledPrice is a future price change. With EndsAt it is a sale that
+// reverts to OriginalPrice when it ends; without, the change is permanent.
+type ScheduledPrice struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ItemID        uint       `json:"item_id" gorm:"not null;index"`
+	Price         float64    `json:"price" gorm:"not null"`
+	StartsAt      time.Time  `json:"starts_at" gorm:"not null;index"`
+	EndsAt        *time.Time `json:"ends_at"`
+	Status        string     `json:"status" gorm:"not null;default:'pending';index"`
+	OriginalPrice *float64   `json:"original_price"`
+	CreatedByID   uint       `json:"created_by_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
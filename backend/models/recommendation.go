@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ItemRecommendation is a precomputed related item. CoPurchases counts the
+// orders that contained both items; Score adds a bonus for sharing a
+// category. Rows are rebuilt by a background job.
+type ItemRecommendation struct {
+	ItemID        uint      `json:"item_id" gorm:"primaryKey;autoIncrement:false"`
+	RelatedItemID uint      `json:"related_item_id" gorm:"primaryKey;autoIncrement:false;index"`
+	Score         float64   `json:"score" gorm:"not null"`
+	CoPurchases   int       `json:"co_purchases" gorm:"not null;default:0"`
+	SameCategory  bool      `json:"same_category" gorm:"not null;default:false"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a one-time code that can replace a TOTP code when the
+// user has lost their authenticator. Only the hash is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
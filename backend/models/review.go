@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's rating of an item. Only approved reviews count
+// towards Item.Rating and Item.Reviews.
+type Review struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	ItemID    uint           `json:"item_id" gorm:"not null;index"`
+	UserID    uint           `json:"user_id" gorm:"not null;index"`
+	User      User           `json:"-" gorm:"foreignKey:UserID"`
+	Rating    int            `json:"rating" gorm:"not null"`
+	Title     string         `json:"title"`
+	Body      string         `json:"body"`
+	Status    string         `json:"status" gorm:"not null;default:'approved';index"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
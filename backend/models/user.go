@@ -1,19 +1,82 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	Username  string         `json:"username" gorm:"unique;not null"`
-	Password  string         `json:"password" gorm:"not null"`
-	Token     string         `json:"token"`
+	Password  string         `json:"-" gorm:"not null"`
+	Role      string         `json:"role" gorm:"not null;default:'customer'"`
+	Token     string         `json:"-"`
+	Email     string         `json:"email" gorm:"index"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	DisplayName string      `json:"display_name"`
+	Preferences Preferences `json:"preferences"`
+
 	CartID    *uint          `json:"cart_id" gorm:"unique"`
 	Cart      *Cart          `json:"cart" gorm:"foreignKey:CartID"`
+
+	Identities []UserIdentity `json:"identities,omitempty" gorm:"foreignKey:UserID"`
+
+	TwoFactorEnabled  bool   `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TwoFactorRequired bool   `json:"two_factor_required" gorm:"not null;default:false"`
+	TwoFactorSecret   string `json:"-"`
+	TwoFactorLastStep int64  `json:"-" gorm:"not null;default:0"`
+
+	TokenVersion        uint       `json:"-" gorm:"not null;default:0"`
+	FailedLoginAttempts int        `json:"-" gorm:"not null;default:0"`
+	LockedUntil         *time.Time `json:"-"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
-} 
\ No newline at end of file
+}
+
+// Preferences holds free-form user settings, stored as a JSON column
+type Preferences map[string]interface{}
+
+func (p Preferences) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (p *Preferences) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return errors.New("unsupported type for preferences")
+	}
+	return json.Unmarshal(data, p)
+}
+
+func (Preferences) GormDataType() string {
+	return "text"
+}
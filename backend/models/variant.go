@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OptionType is a dimension an item varies in, such as "Size" or "Color"
+type OptionType struct {
+	ID       uint          `json:"id" gorm:"primaryKey"`
+	ItemID   uint          `json:"item_id" gorm:"not null;index"`
+	Name     string        `json:"name" gorm:"not null"`
+	Position int           `json:"position" gorm:"not null;default:0"`
+	Values   []OptionValue `json:"values" gorm:"foreignKey:OptionTypeID"`
+}
+
+// OptionValue is one choice of an option type, such as "M" or "Red"
+type OptionValue struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	OptionTypeID uint   `json:"option_type_id" gorm:"not null;index"`
+	Value        string `json:"value" gorm:"not null"`
+	Position     int    `json:"position" gorm:"not null;default:0"`
+}
+
+// Variant is a purchasable combination of option values with its own SKU
+// and stock. A nil Price means the item price applies.
+type Variant struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	ItemID    uint           `json:"item_id" gorm:"not null;index"`
+	SKU       string         `json:"sku" gorm:"not null;index"`
+	Price     *float64       `json:"price"`
+	Stock     int            `json:"stock" gorm:"not null;default:0"`
+	Image     string         `json:"image"`
+	Options   []OptionValue  `json:"options" gorm:"many2many:variant_option_values"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// EffectivePrice returns the variant price, falling back to the item price
+func (v Variant) EffectivePrice(item Item) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return item.Price
+}
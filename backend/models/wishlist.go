@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// Wishlist holds the items a user is interested in. A ShareToken makes it
+// readable by anyone with the share link.
+type Wishlist struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"user_id" gorm:"not null;uniqueIndex"`
+	ShareToken *string        `json:"-" gorm:"uniqueIndex"`
+	Items      []WishlistItem `json:"items" gorm:"foreignKey:WishlistID"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// WishlistItem is an item, or one of its variants, on a wishlist.
+// LastPrice and LastInStock are what the user was last told about, so a
+// price drop or restock is only signalled once.
+type WishlistItem struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	WishlistID  uint      `json:"wishlist_id" gorm:"not null;index"`
+	ItemID      uint      `json:"item_id" gorm:"not null;index"`
+	Item        Item      `json:"item" gorm:"foreignKey:ItemID"`
+	VariantID   *uint     `json:"variant_id" gorm:"index"`
+	Variant     *Variant  `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	AddedPrice  float64   `json:"added_price" gorm:"not null"`
+	LastPrice   float64   `json:"-" gorm:"not null"`
+	LastInStock bool      `json:"-" gorm:"not null;default:false"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CurrentPrice returns the price of the wishlisted variant or item. Item
+// and Variant must be loaded.
+func (w WishlistItem) CurrentPrice() float64 {
+	if w.Variant != nil {
+		return w.Variant.EffectivePrice(w.Item)
+	}
+	return w.Item.Price
+}
+
+// Available reports whether the wishlisted item can be bought at now
+func (w WishlistItem) Available(now time.Time) bool {
+	if w.Item.EffectiveStatus(now) != ItemActive || !w.Item.InStock {
+		return false
+	}
+	if w.VariantID != nil {
+		return w.Variant != nil && w.Variant.Stock > 0
+	}
+	return true
+}
@@ -4,11 +4,17 @@ import (
 	"net/http"
 	"shopping-cart/controllers"
 	"shopping-cart/middlewares"
+	"shopping-cart/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(r *gin.Engine) {
+	// Rate limiters per route group, configurable through the environment
+	authLimiter := utils.NewRateLimiterFromEnv("RATE_LIMIT_AUTH", 1, 10)
+	publicLimiter := utils.NewRateLimiterFromEnv("RATE_LIMIT_PUBLIC", 10, 60)
+	protectedLimiter := utils.NewRateLimiterFromEnv("RATE_LIMIT_PROTECTED", 20, 100)
+
 	// Root route to show server is running
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -20,55 +26,234 @@ func SetupRoutes(r *gin.Engine) {
 					"POST /users": "Sign up a new user",
 					"POST /users/login": "Login user",
 					"GET /users": "List all users (protected)",
+					"GET /users/me": "Get own profile (protected)",
+					"GET /users/me/recently-viewed": "Recently viewed items of the user, or of the guest in X-Guest-Token",
+					"POST /guest-token": "Issue a guest token whose item views are recorded",
+					"PATCH /users/me": "Update email, display name and preferences (protected)",
+					"DELETE /users/me": "Anonymize own account, keeping order history (protected)",
+					"POST /users/me/password": "Change password, revokes other sessions (protected)",
+					"GET /auth/oidc/login": "Sign in through the configured OpenID Connect provider",
+					"GET /auth/oidc/callback": "OpenID Connect redirect target, returns the login response",
+					"POST /users/login/2fa": "Complete a two-factor login with a TOTP or recovery code",
+					"POST /users/me/2fa/enroll": "Start TOTP enrollment (protected)",
+					"POST /users/me/2fa/confirm": "Confirm TOTP enrollment and get recovery codes (protected)",
+					"POST /users/me/2fa/recovery-codes": "Regenerate recovery codes (protected)",
+					"DELETE /users/me/2fa": "Disable two-factor authentication (protected)",
+					"PUT /admin/users/:id/2fa": "Require two-factor authentication for a user (admin)",
+					"POST /users/verify": "Verify email address with a token",
+					"POST /users/verify/resend": "Resend the verification email (protected)",
+					"POST /users/password/reset": "Request a password reset email",
+					"POST /users/password/reset/confirm": "Set a new password with a reset token",
+				},
+				"api_keys": gin.H{
+					"POST /admin/api-keys": "Create a scoped API key, returned once (admin)",
+					"GET /admin/api-keys": "List API keys (admin)",
+					"DELETE /admin/api-keys/:id": "Revoke an API key (admin)",
+				},
+				"keys": gin.H{
+					"GET /.well-known/jwks.json": "Public keys for verifying issued tokens",
 				},
 				"items": gin.H{
-					"GET /items": "List all items",
-					"POST /items": "Create new item (protected)",
-					"DELETE /items/:id": "Delete item (protected)",
+					"GET /items": "List published items with facet counts; filter by category_id, min_price, max_price, min_rating, in_stock; sort=trending, price_asc, price_desc, rating or newest; admins see drafts and archived items too",
+					"GET /items/:id": "Get item details, drafts only for admins",
+					"POST /items": "Create new item (admin)",
+					"PUT /items/:id": "Update item, honours If-Match (admin)",
+					"DELETE /items/:id": "Delete item (admin)",
+					"GET /items/:id/variants": "List option types and variants of an item",
+					"POST /items/:id/options": "Add an option type with its values (admin)",
+					"DELETE /items/:id/options/:optionId": "Delete an unused option type (admin)",
+					"POST /items/:id/variants": "Add a variant with SKU, price, stock and image (admin)",
+					"PUT /items/:id/variants/:variantId": "Update a variant (admin)",
+					"DELETE /items/:id/variants/:variantId": "Delete a variant (admin)",
+					"GET /items/:id/images": "List item images in display order",
+					"POST /items/:id/images": "Upload an image as multipart field image (admin)",
+					"PUT /items/:id/images/order": "Reorder item images (admin)",
+					"DELETE /items/:id/images/:imageId": "Delete an item image (admin)",
+					"GET /items/:id/prices": "Current, was price and price history of an item",
+					"GET /items/:id/related": "Related and frequently bought together items",
+					"GET /items/:id/prices/schedule": "List scheduled price changes (admin)",
+					"POST /items/:id/prices/schedule": "Schedule a price change or time-boxed sale (admin)",
+					"DELETE /items/:id/prices/schedule/:scheduleId": "Cancel a scheduled price change or end a sale (admin)",
+					"GET /uploads/*filepath": "Uploaded images and thumbnails",
+				},
+				"reviews": gin.H{
+					"GET /items/:id/reviews": "Approved reviews, sort=newest|oldest|highest|lowest, paginated",
+					"POST /items/:id/reviews": "Review an item from a delivered order (protected)",
+					"GET /admin/reviews": "List reviews for moderation, filter by status (admin)",
+					"PUT /admin/reviews/:id": "Approve or reject a review (admin)",
+					"DELETE /admin/reviews/:id": "Delete a review (admin)",
+				},
+				"catalog": gin.H{
+					"POST /admin/catalog/import": "Upsert items by SKU from CSV or JSON Lines, dry_run=true to validate only (admin)",
+					"GET /admin/catalog/export": "Stream the catalog as format=csv or format=jsonl (admin)",
+				},
+				"search": gin.H{
+					"GET /search": "Full-text search over item name, category and description, ranked by relevance",
+					"GET /search/suggest": "Autocomplete the last word of q",
+				},
+				"categories": gin.H{
+					"GET /categories": "Category tree with item counts",
+					"GET /categories/:id": "Get category and its direct subcategories",
+					"POST /categories": "Create category (admin)",
+					"PUT /categories/:id": "Rename, move or reorder category (admin)",
+					"DELETE /categories/:id": "Delete empty category (admin)",
 				},
 				"cart": gin.H{
 					"POST /carts": "Add item to cart (protected)",
 					"DELETE /carts": "Remove item from cart (protected)",
 					"GET /carts": "Get user's cart (protected)",
-					"GET /carts/all": "List all carts (protected)",
+					"GET /carts/all": "List all carts (admin)",
+					"GET /carts/recommendations": "Items frequently bought with the cart contents (protected)",
+				},
+				"wishlist": gin.H{
+					"GET /wishlist": "Get user's wishlist with current prices and stock (protected)",
+					"POST /wishlist/items": "Add an item to the wishlist (protected)",
+					"DELETE /wishlist/items/:id": "Remove an item from the wishlist (protected)",
+					"POST /wishlist/items/:id/move-to-cart": "Move a wishlist item into the cart (protected)",
+					"POST /wishlist/share": "Create a read-only share link (protected)",
+					"DELETE /wishlist/share": "Revoke the share link (protected)",
+					"GET /wishlists/shared/:token": "View a shared wishlist",
 				},
 				"orders": gin.H{
 					"POST /orders": "Create order from cart (protected)",
 					"GET /orders": "List user's orders (protected)",
-					"GET /orders/all": "List all orders (protected)",
+					"GET /orders/:id/invoice": "Download an order invoice as HTML or PDF with ?format=pdf (protected)",
+					"GET /orders/all": "List all orders, filtered by from, to, status and user_id (admin)",
+					"PUT /admin/orders/:id/status": "Move an order to pending, paid, shipped, delivered or cancelled (admin)",
+				},
+				"reports": gin.H{
+					"GET /admin/reports/orders/export": "Stream filtered order lines as format=csv or format=jsonl (admin)",
+					"GET /admin/reports/revenue": "Revenue, tax and average order value by day, week or month (admin)",
+					"GET /admin/reports/top-items": "Items ranked by revenue (admin)",
+					"GET /admin/reports/top-categories": "Categories ranked by revenue (admin)",
+					"GET /admin/metrics": "Orders, revenue, signups, carts and conversion over time by hour, day, week or month (admin)",
 				},
 			},
 		})
 	})
 
+	// Uploaded files are served outside the rate limited groups
+	r.GET("/uploads/*filepath", controllers.ServeUpload)
+
 	// Public routes
 	public := r.Group("/")
+	public.Use(middlewares.RateLimitByIP(publicLimiter))
 	{
-		public.POST("/users", controllers.Signup)
-		public.POST("/users/login", controllers.Login)
-		public.GET("/items", controllers.ListItems)
+		public.POST("/users", middlewares.RateLimitByIP(authLimiter), controllers.Signup)
+		public.POST("/users/login", middlewares.RateLimitByIP(authLimiter), controllers.Login)
+		public.POST("/users/login/2fa", middlewares.RateLimitByIP(authLimiter), controllers.LoginTwoFactor)
+		public.POST("/users/verify", middlewares.RateLimitByIP(authLimiter), controllers.VerifyEmail)
+		public.POST("/users/password/reset", middlewares.RateLimitByIP(authLimiter), controllers.RequestPasswordReset)
+		public.POST("/users/password/reset/confirm", middlewares.RateLimitByIP(authLimiter), controllers.ConfirmPasswordReset)
+		public.GET("/.well-known/jwks.json", controllers.GetJWKS)
+		public.GET("/auth/oidc/login", middlewares.RateLimitByIP(authLimiter), controllers.OIDCLogin)
+		public.GET("/auth/oidc/callback", middlewares.RateLimitByIP(authLimiter), controllers.OIDCCallback)
+		public.GET("/items", middlewares.OptionalAuthMiddleware(), controllers.ListItems)
+		public.GET("/users/me/recently-viewed", middlewares.OptionalAuthMiddleware(), controllers.GetRecentlyViewed)
+		public.POST("/guest-token", middlewares.RateLimitByIP(authLimiter), controllers.IssueGuestToken)
+		public.GET("/items/:id", middlewares.OptionalAuthMiddleware(), controllers.GetItem)
+		public.GET("/items/:id/variants", middlewares.OptionalAuthMiddleware(), controllers.ListVariants)
+		public.GET("/items/:id/reviews", middlewares.OptionalAuthMiddleware(), controllers.ListItemReviews)
+		public.GET("/items/:id/images", middlewares.OptionalAuthMiddleware(), controllers.ListItemImages)
+		public.GET("/items/:id/prices", middlewares.OptionalAuthMiddleware(), controllers.GetItemPrices)
+		public.GET("/items/:id/related", middlewares.OptionalAuthMiddleware(), controllers.GetRelatedItems)
+		public.GET("/search", middlewares.OptionalAuthMiddleware(), controllers.SearchItems)
+		public.GET("/search/suggest", controllers.SuggestSearch)
+		public.GET("/categories", controllers.ListCategories)
+		public.GET("/categories/:id", controllers.GetCategory)
+		public.GET("/wishlists/shared/:token", controllers.GetSharedWishlist)
 	}
 
 	// Protected routes
 	protected := r.Group("/")
 	protected.Use(middlewares.AuthMiddleware())
+	protected.Use(middlewares.RateLimitByUser(protectedLimiter))
 	{
 		// User routes
-		protected.GET("/users", controllers.ListUsers)
+		protected.GET("/users", middlewares.RequireScope("users:read"), controllers.ListUsers)
+		protected.GET("/users/me", middlewares.RequireScope("users:read"), controllers.GetProfile)
+		protected.PATCH("/users/me", middlewares.RequireScope("users:write"), controllers.UpdateProfile)
+		protected.DELETE("/users/me", middlewares.RequireSession(), controllers.DeleteAccount)
+		protected.POST("/users/me/password", middlewares.RequireSession(), controllers.ChangePassword)
+		protected.POST("/users/verify/resend", middlewares.RequireScope("users:write"), controllers.ResendVerificationEmail)
+		protected.POST("/users/me/2fa/enroll", middlewares.RequireSession(), controllers.EnrollTwoFactor)
+		protected.POST("/users/me/2fa/confirm", middlewares.RequireSession(), controllers.ConfirmTwoFactor)
+		protected.POST("/users/me/2fa/recovery-codes", middlewares.RequireSession(), controllers.RegenerateRecoveryCodes)
+		protected.DELETE("/users/me/2fa", middlewares.RequireSession(), controllers.DisableTwoFactor)
 
 		// Item routes
-		protected.POST("/items", controllers.CreateItem)
-		protected.DELETE("/items/:id", controllers.DeleteItem)
+		protected.POST("/items", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.CreateItem)
+		protected.PUT("/items/:id", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.UpdateItem)
+		protected.DELETE("/items/:id", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.DeleteItem)
+
+		// Review routes
+		protected.POST("/items/:id/reviews", middlewares.RequireScope("users:write"), controllers.CreateReview)
+
+		// Variant routes
+		protected.POST("/items/:id/options", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.CreateOptionType)
+		protected.DELETE("/items/:id/options/:optionId", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.DeleteOptionType)
+		protected.POST("/items/:id/variants", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.CreateVariant)
+		protected.PUT("/items/:id/variants/:variantId", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.UpdateVariant)
+		protected.DELETE("/items/:id/variants/:variantId", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.DeleteVariant)
+
+		// Image routes
+		protected.POST("/items/:id/images", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.UploadItemImage)
+		protected.PUT("/items/:id/images/order", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.ReorderItemImages)
+		protected.DELETE("/items/:id/images/:imageId", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.DeleteItemImage)
+
+		// Price routes
+		protected.GET("/items/:id/prices/schedule", middlewares.AdminMiddleware(), controllers.ListScheduledPrices)
+		protected.POST("/items/:id/prices/schedule", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.SchedulePriceChange)
+		protected.DELETE("/items/:id/prices/schedule/:scheduleId", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.CancelScheduledPrice)
+
+		// Category routes
+		protected.POST("/categories", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.CreateCategory)
+		protected.PUT("/categories/:id", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.UpdateCategory)
+		protected.DELETE("/categories/:id", middlewares.AdminMiddleware(), middlewares.RequireScope("items:write"), controllers.DeleteCategory)
 
 		// Cart routes
-		protected.POST("/carts", controllers.AddToCart)
-		protected.DELETE("/carts", controllers.RemoveFromCart)
-		protected.GET("/carts", controllers.GetCart)
-		protected.GET("/carts/all", controllers.ListCarts) // Admin only
+		protected.POST("/carts", middlewares.RequireScope("carts:write"), middlewares.IdempotencyMiddleware(), controllers.AddToCart)
+		protected.DELETE("/carts", middlewares.RequireScope("carts:write"), controllers.RemoveFromCart)
+		protected.GET("/carts", middlewares.RequireScope("carts:read"), controllers.GetCart)
+		protected.GET("/carts/all", middlewares.AdminMiddleware(), middlewares.RequireScope("admin"), controllers.ListCarts)
+		protected.GET("/carts/recommendations", middlewares.RequireScope("carts:read"), controllers.GetCartRecommendations)
+
+		// Wishlist routes
+		protected.GET("/wishlist", middlewares.RequireScope("carts:read"), controllers.GetWishlist)
+		protected.POST("/wishlist/items", middlewares.RequireScope("carts:write"), controllers.AddToWishlist)
+		protected.DELETE("/wishlist/items/:id", middlewares.RequireScope("carts:write"), controllers.RemoveFromWishlist)
+		protected.POST("/wishlist/items/:id/move-to-cart", middlewares.RequireScope("carts:write"), controllers.MoveWishlistItemToCart)
+		protected.POST("/wishlist/share", middlewares.RequireScope("carts:write"), controllers.ShareWishlist)
+		protected.DELETE("/wishlist/share", middlewares.RequireScope("carts:write"), controllers.UnshareWishlist)
 
 		// Order routes
-		protected.POST("/orders", controllers.CreateOrder)
-		protected.GET("/orders", controllers.ListOrders)
-		protected.GET("/orders/all", controllers.ListAllOrders) // Admin only
+		protected.POST("/orders", middlewares.RequireScope("orders:write"), middlewares.IdempotencyMiddleware(), controllers.CreateOrder)
+		protected.GET("/orders", middlewares.RequireScope("orders:read"), controllers.ListOrders)
+		protected.GET("/orders/:id/invoice", middlewares.RequireScope("orders:read"), controllers.GetOrderInvoice)
+		protected.GET("/orders/all", middlewares.AdminMiddleware(), middlewares.RequireScope("admin"), controllers.ListAllOrders)
+	}
+
+	// Admin routes
+	admin := protected.Group("/admin")
+	admin.Use(middlewares.AdminMiddleware())
+	admin.Use(middlewares.RequireScope("admin"))
+	{
+		admin.PUT("/users/:id/2fa", controllers.SetTwoFactorRequired)
+		admin.PUT("/orders/:id/status", controllers.UpdateOrderStatus)
+		admin.POST("/catalog/import", middlewares.RequireScope("items:write"), controllers.ImportCatalog)
+		admin.GET("/catalog/export", controllers.ExportCatalog)
+		admin.GET("/reports/orders/export", controllers.ExportOrders)
+		admin.GET("/reports/revenue", controllers.GetRevenueReport)
+		admin.GET("/reports/top-items", controllers.GetTopItemsReport)
+		admin.GET("/reports/top-categories", controllers.GetTopCategoriesReport)
+		admin.GET("/metrics", controllers.GetMetrics)
+		admin.GET("/reviews", controllers.ListReviewsForModeration)
+		admin.PUT("/reviews/:id", controllers.ModerateReview)
+		admin.DELETE("/reviews/:id", controllers.DeleteReview)
+
+		// API keys can only be managed from an interactive session
+		admin.POST("/api-keys", middlewares.RequireSession(), controllers.CreateAPIKey)
+		admin.GET("/api-keys", middlewares.RequireSession(), controllers.ListAPIKeys)
+		admin.DELETE("/api-keys/:id", middlewares.RequireSession(), controllers.RevokeAPIKey)
 	}
 } 
\ No newline at end of file
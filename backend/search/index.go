@@ -0,0 +1,346 @@
+// Package search is an in-memory inverted index with BM25 ranking over
+// weighted fields, typo tolerant matching and autocomplete suggestions.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Weights of terms matched other than exactly
+const (
+	typoWeight   = 0.7
+	prefixWeight = 0.8
+)
+
+// Field is a searchable part of a document. Matches in fields with a
+// higher weight rank higher.
+type Field struct {
+	Name   string
+	Weight float64
+}
+
+// Document is indexed text keyed by ID. Only documents with Suggest set
+// contribute words to suggestions.
+type Document struct {
+	ID         uint
+	Fields     map[string]string
+	Suggest    bool
+	Visibility Visibility
+}
+
+// Visibility is when a document shows up in SearchVisible. The zero value
+// is always visible; From and Until bound the time it is, when set.
+type Visibility struct {
+	Hidden bool
+	From   *time.Time
+	Until  *time.Time
+}
+
+// VisibleAt reports whether the document is visible at now
+func (v Visibility) VisibleAt(now time.Time) bool {
+	if v.Hidden || (v.From != nil && now.Before(*v.From)) {
+		return false
+	}
+	return v.Until == nil || now.Before(*v.Until)
+}
+
+// Result is a matching document and its relevance score
+type Result struct {
+	ID    uint
+	Score float64
+}
+
+type indexedDoc struct {
+	lengths    []int
+	terms      []string
+	words      []string
+	visibility Visibility
+}
+
+// Index is safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	fields   []Field
+	docs     map[uint]*indexedDoc
+	postings map[string]map[uint][]int
+	totals   []int
+	words    map[string]int
+}
+
+// NewIndex returns an empty index over fields
+func NewIndex(fields ...Field) *Index {
+	idx := &Index{fields: fields}
+	idx.reset()
+	return idx
+}
+
+func (idx *Index) reset() {
+	idx.docs = map[uint]*indexedDoc{}
+	idx.postings = map[string]map[uint][]int{}
+	idx.totals = make([]int, len(idx.fields))
+	idx.words = map[string]int{}
+}
+
+// Len returns the number of indexed documents
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Add indexes doc, replacing an earlier version with the same ID
+func (idx *Index) Add(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ID)
+	idx.add(doc)
+}
+
+// Remove drops a document from the index
+func (idx *Index) Remove(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+// Replace swaps the whole index content for docs
+func (idx *Index) Replace(docs []Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.reset()
+	for _, doc := range docs {
+		idx.add(doc)
+	}
+}
+
+func (idx *Index) add(doc Document) {
+	entry := &indexedDoc{lengths: make([]int, len(idx.fields)), visibility: doc.Visibility}
+	seenWords := map[string]bool{}
+	for i, field := range idx.fields {
+		text := doc.Fields[field.Name]
+		terms := Tokenize(text)
+		entry.lengths[i] = len(terms)
+		idx.totals[i] += len(terms)
+		for _, term := range terms {
+			posting := idx.postings[term]
+			if posting == nil {
+				posting = map[uint][]int{}
+				idx.postings[term] = posting
+			}
+			if posting[doc.ID] == nil {
+				posting[doc.ID] = make([]int, len(idx.fields))
+				entry.terms = append(entry.terms, term)
+			}
+			posting[doc.ID][i]++
+		}
+
+		if doc.Suggest {
+			for _, word := range Words(text) {
+				if !seenWords[word] {
+					seenWords[word] = true
+					entry.words = append(entry.words, word)
+					idx.words[word]++
+				}
+			}
+		}
+	}
+	idx.docs[doc.ID] = entry
+}
+
+func (idx *Index) remove(id uint) {
+	entry, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for i, length := range entry.lengths {
+		idx.totals[i] -= length
+	}
+	for _, term := range entry.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	for _, word := range entry.words {
+		if idx.words[word]--; idx.words[word] <= 0 {
+			delete(idx.words, word)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Search ranks documents matching every word of query that is known to
+// the index. Words match exactly after stemming, with a few typos or, for
+// the last word, as a prefix; inexact matches score lower.
+func (idx *Index) Search(query string) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.search(query, func(doc *indexedDoc) bool { return true })
+}
+
+// SearchVisible is Search limited to the documents visible at now
+func (idx *Index) SearchVisible(query string, now time.Time) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.search(query, func(doc *indexedDoc) bool { return doc.visibility.VisibleAt(now) })
+}
+
+func (idx *Index) search(query string, keep func(doc *indexedDoc) bool) []Result {
+	words := Words(query)
+	if len(words) == 0 || len(idx.docs) == 0 {
+		return nil
+	}
+
+	var scores map[uint]float64
+	for i, word := range words {
+		matches := idx.expand(word, i == len(words)-1 && !strings.HasSuffix(query, " "))
+		if len(matches) == 0 {
+			continue
+		}
+
+		wordScores := map[uint]float64{}
+		for term, weight := range matches {
+			for id, score := range idx.scoreTerm(term) {
+				// A document counts the best of its matches for this word
+				wordScores[id] = math.Max(wordScores[id], score*weight)
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id := range scores {
+			if score, ok := wordScores[id]; ok {
+				scores[id] += score
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		if keep(idx.docs[id]) {
+			results = append(results, Result{ID: id, Score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+// expand maps a query word to the indexed terms it matches and their weight
+func (idx *Index) expand(word string, prefix bool) map[string]float64 {
+	stem := Stem(word)
+	matches := map[string]float64{}
+	_, exact := idx.postings[stem]
+	if exact {
+		matches[stem] = 1
+	}
+
+	// Typos are only considered for words the index doesn't know
+	limit := 0
+	if !exact {
+		limit = maxEdits(len([]rune(word)))
+	}
+	for term := range idx.postings {
+		if term == stem {
+			continue
+		}
+		if prefix && len(word) >= 3 && strings.HasPrefix(term, word) {
+			matches[term] = prefixWeight
+		} else if limit > 0 {
+			if d := editDistance(stem, term, limit); d <= limit {
+				matches[term] = math.Pow(typoWeight, float64(d))
+			}
+		}
+	}
+	return matches
+}
+
+// scoreTerm returns the BM25F score of term for every document containing it
+func (idx *Index) scoreTerm(term string) map[uint]float64 {
+	posting := idx.postings[term]
+	n := float64(len(idx.docs))
+	df := float64(len(posting))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	scores := make(map[uint]float64, len(posting))
+	for id, counts := range posting {
+		doc := idx.docs[id]
+		tf := 0.0
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+			avg := float64(idx.totals[i]) / n
+			norm := 1 - b + b*float64(doc.lengths[i])/avg
+			tf += idx.fields[i].Weight * float64(count) / norm
+		}
+		scores[id] = idf * tf * (k1 + 1) / (tf + k1)
+	}
+	return scores
+}
+
+// Suggest completes the last word of query with words from the index,
+// most common first, allowing a typo when nothing matches exactly
+func (idx *Index) Suggest(query string, limit int) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	words := Words(query)
+	if len(words) == 0 || strings.HasSuffix(query, " ") {
+		return []string{}
+	}
+	last := words[len(words)-1]
+	lead := strings.Join(words[:len(words)-1], " ")
+
+	candidates := idx.completions(func(word string) bool { return strings.HasPrefix(word, last) })
+	if length := len([]rune(last)); len(candidates) == 0 && maxEdits(length) > 0 {
+		candidates = idx.completions(func(word string) bool {
+			runes := []rune(word)
+			return len(runes) >= length && editDistance(last, string(runes[:length]), 1) <= 1
+		})
+	}
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	suggestions := make([]string, len(candidates))
+	for i, word := range candidates {
+		suggestions[i] = strings.TrimSpace(lead + " " + word)
+	}
+	return suggestions
+}
+
+func (idx *Index) completions(match func(word string) bool) []string {
+	var candidates []string
+	for word := range idx.words {
+		if match(word) {
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := idx.words[candidates[i]], idx.words[candidates[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"with": true,
+}
+
+// Words splits text into lower case words of letters and digits,
+// leaving out stop words
+func Words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := fields[:0]
+	for _, word := range fields {
+		if !stopWords[word] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// Tokenize returns the stemmed terms of text
+func Tokenize(text string) []string {
+	words := Words(text)
+	for i, word := range words {
+		words[i] = Stem(word)
+	}
+	return words
+}
+
+// Stem reduces an English word to a stem by removing plural and common
+// suffixes. It is deliberately light: the same stemmer runs on documents
+// and queries, so it only has to be consistent, not linguistically exact.
+func Stem(word string) string {
+	if len(word) <= 3 || !isAlpha(word) {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "es") && hasAnySuffix(word[:len(word)-2], "s", "x", "z", "ch", "sh"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !hasAnySuffix(word, "ss", "us", "is"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed", "ly", "ness", "ment"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			word = word[:len(word)-len(suffix)]
+			// running -> runn -> run
+			if n := len(word); word[n-1] == word[n-2] && !strings.ContainsRune("aeiouslz", rune(word[n-1])) {
+				word = word[:n-1]
+			}
+			break
+		}
+	}
+
+	if strings.HasSuffix(word, "e") && len(word) > 3 {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+func hasAnySuffix(word string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlpha(word string) bool {
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// maxEdits is how many typos a query word of the given length may contain
+func maxEdits(length int) int {
+	switch {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance is the Damerau-Levenshtein distance between a and b (with
+// adjacent transpositions), giving up once it exceeds limit
+func editDistance(a, b string, limit int) int {
+	ar, br := []rune(a), []rune(b)
+	if abs(len(ar)-len(br)) > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(br)+1)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(br)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
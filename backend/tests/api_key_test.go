@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeys(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "keyadmin", "password123")
+	customerToken := SignupAndLogin(router, "keycustomer", "password123")
+
+	var key string
+	var keyID float64
+
+	t.Run("should only let admins create keys", func(t *testing.T) {
+		body := map[string]interface{}{"name": "catalog sync", "scopes": []string{"items:write"}}
+		w := PerformRequest(router, "POST", "/admin/api-keys", body, customerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "POST", "/admin/api-keys", map[string]interface{}{"name": "bad", "scopes": []string{"everything"}}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "POST", "/admin/api-keys", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		response := DecodeBody(w)
+		key = response["key"].(string)
+		apiKey := response["api_key"].(map[string]interface{})
+		keyID = apiKey["id"].(float64)
+		assert.Contains(t, key, "sk_")
+		assert.Equal(t, key[:11], apiKey["prefix"])
+
+		// Only the hash is stored
+		var stored models.APIKey
+		db.First(&stored, uint(keyID))
+		assert.NotContains(t, stored.KeyHash, key)
+	})
+
+	t.Run("should enforce scopes", func(t *testing.T) {
+		headers := map[string]string{"X-API-Key": key}
+		item := map[string]interface{}{"name": "Synced Item", "price": 5.0}
+		w := PerformRequest(router, "POST", "/items", item, "", headers)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = PerformRequest(router, "GET", "/orders", nil, "", headers)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// The owner is an admin, but the key lacks the admin scope
+		w = PerformRequest(router, "GET", "/orders/all", nil, "", headers)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "POST", "/users/me/password", map[string]interface{}{"old_password": "password123", "new_password": "password456"}, "", headers)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "GET", "/carts", nil, "", map[string]string{"X-API-Key": "sk_invalid"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should act as the given user and record last use", func(t *testing.T) {
+		var customer models.User
+		db.Where("username = ?", "keycustomer").First(&customer)
+
+		body := map[string]interface{}{"name": "orders export", "scopes": []string{"orders:read"}, "user_id": customer.ID}
+		w := PerformRequest(router, "POST", "/admin/api-keys", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		customerKey := DecodeBody(w)["key"].(string)
+
+		w = PerformRequest(router, "GET", "/orders", nil, "", map[string]string{"X-API-Key": customerKey})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/admin/api-keys", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		keys := DecodeBody(w)["api_keys"].([]interface{})
+		assert.Equal(t, 2, len(keys))
+		assert.NotNil(t, keys[1].(map[string]interface{})["last_used_at"])
+	})
+
+	t.Run("should record last use on public routes", func(t *testing.T) {
+		body := map[string]interface{}{"name": "storefront", "scopes": []string{"items:write"}}
+		w := PerformRequest(router, "POST", "/admin/api-keys", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		response := DecodeBody(w)
+		storefrontKey := response["key"].(string)
+		storefrontID := uint(response["api_key"].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "GET", "/items", nil, "", map[string]string{"X-API-Key": storefrontKey})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var stored models.APIKey
+		db.First(&stored, storefrontID)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("should reject expired and revoked keys", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		body := map[string]interface{}{"name": "short lived", "scopes": []string{"carts:read"}, "expires_at": expiresAt}
+		w := PerformRequest(router, "POST", "/admin/api-keys", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		response := DecodeBody(w)
+		shortKey := response["key"].(string)
+		shortID := uint(response["api_key"].(map[string]interface{})["id"].(float64))
+
+		db.Model(&models.APIKey{}).Where("id = ?", shortID).Update("expires_at", time.Now().Add(-time.Minute))
+		w = PerformRequest(router, "GET", "/carts", nil, "", map[string]string{"X-API-Key": shortKey})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("/admin/api-keys/%d", uint(keyID)), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Late Item", "price": 1.0}, "", map[string]string{"X-API-Key": key})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
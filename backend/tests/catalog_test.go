@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shopping-cart/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// postCatalog sends a catalog file as the raw request body
+func postCatalog(router *gin.Engine, token, query, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/admin/catalog/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCatalogImportExport(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "catalogadmin", "password123")
+	customerToken := SignupAndLogin(router, "catalogcustomer", "password123")
+
+	csvFile := "sku,name,description,price,category,in_stock\n" +
+		"LAMP-1,Desk Lamp,LED lamp,29.90,Home,true\n" +
+		"MUG-1,Coffee Mug,,8.50,home,false\n"
+
+	t.Run("should report per-row errors without importing", func(t *testing.T) {
+		w := postCatalog(router, customerToken, "", "text/csv", csvFile)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		invalid := "sku,name,price\nBAD-1,,abc\nLAMP-1,Lamp,3\nLAMP-1,Lamp again,4\n"
+		w = postCatalog(router, adminToken, "", "text/csv", invalid)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		result := DecodeBody(w)["result"].(map[string]interface{})
+		rowErrors := result["errors"].([]interface{})
+		assert.Equal(t, 2, len(rowErrors))
+		first := rowErrors[0].(map[string]interface{})
+		assert.Equal(t, float64(1), first["row"])
+		assert.Equal(t, 3, len(first["errors"].([]interface{})))
+		assert.Equal(t, float64(3), rowErrors[1].(map[string]interface{})["row"])
+
+		var count int64
+		db.Model(&models.Item{}).Where("sku <> ''").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("should dry-run a valid file", func(t *testing.T) {
+		w := postCatalog(router, adminToken, "?dry_run=true", "text/csv", csvFile)
+		assert.Equal(t, http.StatusOK, w.Code)
+		result := DecodeBody(w)["result"].(map[string]interface{})
+		assert.Equal(t, float64(2), result["created"])
+		assert.Equal(t, true, result["dry_run"])
+
+		var count int64
+		db.Model(&models.Item{}).Where("sku <> ''").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("should import and upsert by SKU", func(t *testing.T) {
+		w := postCatalog(router, adminToken, "", "text/csv", csvFile)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var mug models.Item
+		db.Where("sku = ?", "MUG-1").First(&mug)
+		assert.Equal(t, "Home", mug.Category)
+		assert.NotNil(t, mug.CategoryID)
+		assert.False(t, mug.InStock)
+
+		// A deleted item comes back when its SKU is imported again
+		db.Delete(&mug)
+		jsonl := `{"sku":"MUG-1","name":"Coffee Mug","price":9.5}` + "\n" +
+			`{"sku":"PEN-1","name":"Pen","price":1.2,"category":"Office"}` + "\n"
+		w = postCatalog(router, adminToken, "", "application/x-ndjson", jsonl)
+		assert.Equal(t, http.StatusOK, w.Code)
+		result := DecodeBody(w)["result"].(map[string]interface{})
+		assert.Equal(t, float64(1), result["created"])
+		assert.Equal(t, float64(1), result["updated"])
+
+		db.Where("sku = ?", "MUG-1").First(&mug)
+		assert.Equal(t, 9.5, mug.Price)
+		// in_stock and category were left out, so they keep their values
+		assert.False(t, mug.InStock)
+		assert.Equal(t, "Home", mug.Category)
+	})
+
+	t.Run("should only update the columns in the file", func(t *testing.T) {
+		w := postCatalog(router, adminToken, "", "text/csv", "sku,name,price\nLAMP-1,Desk Lamp,31\n")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var lamp models.Item
+		db.Where("sku = ?", "LAMP-1").First(&lamp)
+		assert.Equal(t, 31.0, lamp.Price)
+		assert.Equal(t, "LED lamp", lamp.Description)
+		assert.Equal(t, "Home", lamp.Category)
+		assert.True(t, lamp.InStock)
+
+		w = postCatalog(router, adminToken, "", "application/x-ndjson", `{"sku":"LAMP-1","name":"Desk Lamp","price":29.9,"description":null,"in_stock":false}`+"\n")
+		assert.Equal(t, http.StatusOK, w.Code)
+		db.Where("sku = ?", "LAMP-1").First(&lamp)
+		assert.Equal(t, "LED lamp", lamp.Description)
+		assert.False(t, lamp.InStock)
+
+		db.Model(&lamp).Update("in_stock", true)
+	})
+
+	t.Run("should keep SKUs unique", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Other Lamp", "price": 5.0, "sku": "LAMP-1"}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		err := db.Create(&models.Item{Name: "Racing Lamp", Price: 5, SKU: "LAMP-1"}).Error
+		assert.Error(t, err)
+
+		// Items without a SKU don't conflict
+		assert.NoError(t, db.Create(&models.Item{Name: "No SKU 1", Price: 5}).Error)
+		assert.NoError(t, db.Create(&models.Item{Name: "No SKU 2", Price: 5}).Error)
+	})
+
+	t.Run("should export the catalog", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/catalog/export?format=jsonl", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		skus := map[string]bool{}
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var row map[string]interface{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			skus[row["sku"].(string)] = true
+		}
+		assert.True(t, skus["LAMP-1"] && skus["MUG-1"] && skus["PEN-1"])
+
+		w = PerformRequest(router, "GET", "/admin/catalog/export", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Equal(t, "sku,name,description,price,category,image,in_stock", lines[0])
+		assert.Contains(t, w.Body.String(), "LAMP-1,Desk Lamp,LED lamp,29.9,Home,,true")
+
+		w = PerformRequest(router, "GET", "/admin/catalog/export?format=xml", nil, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should import an export back unchanged", func(t *testing.T) {
+		var before int64
+		db.Model(&models.Item{}).Count(&before)
+
+		w := PerformRequest(router, "GET", "/admin/catalog/export", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		// Seeded items have no SKU and get a generated one
+		assert.Contains(t, w.Body.String(), "ITEM-1,Test Item 1,")
+
+		w = postCatalog(router, adminToken, "", "text/csv", w.Body.String())
+		assert.Equal(t, http.StatusOK, w.Code)
+		result := DecodeBody(w)["result"].(map[string]interface{})
+		assert.Equal(t, float64(0), result["created"])
+		assert.Equal(t, float64(before), result["updated"])
+
+		var after int64
+		db.Model(&models.Item{}).Count(&after)
+		assert.Equal(t, before, after)
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, "ITEM-1", item.SKU)
+		assert.Equal(t, "Test Item 1", item.Name)
+		assert.Equal(t, 10.99, item.Price)
+		assert.Equal(t, "Electronics", item.Category)
+	})
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategories(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "catadmin", "password123")
+	customerToken := SignupAndLogin(router, "catcustomer", "password123")
+
+	var electronicsID, audioID uint
+
+	t.Run("should migrate free-text categories", func(t *testing.T) {
+		// Seed data already holds one "Electronics" and one "Books" item
+		db.Create(&models.Item{Name: "Speaker", Price: 10, Category: "Electronics"})
+		db.Create(&models.Item{Name: "Cable", Price: 2, Category: "electronics"})
+		db.Create(&models.Item{Name: "Novel", Price: 8, Category: "Books"})
+
+		assert.NoError(t, utils.MigrateItemCategories(db))
+
+		var categories []models.Category
+		db.Order("slug").Find(&categories)
+		assert.Equal(t, 2, len(categories))
+		assert.Equal(t, "books", categories[0].Slug)
+		assert.Equal(t, "electronics", categories[1].Slug)
+		electronicsID = categories[1].ID
+
+		var linked int64
+		db.Model(&models.Item{}).Where("category_id = ?", electronicsID).Count(&linked)
+		assert.Equal(t, int64(3), linked)
+	})
+
+	t.Run("should only let admins manage categories", func(t *testing.T) {
+		body := map[string]interface{}{"name": "Audio", "parent_id": electronicsID}
+		w := PerformRequest(router, "POST", "/categories", body, customerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "POST", "/categories", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		audioID = uint(DecodeBody(w)["category"].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "POST", "/categories", map[string]interface{}{"name": "AUDIO"}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("should file items by ID or name", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Headphones", "price": 50.0, "category_id": audioID}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		item := DecodeBody(w)["item"].(map[string]interface{})
+		assert.Equal(t, "Audio", item["category"])
+
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Adapter", "price": 5.0, "category": "ELECTRONICS"}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		item = DecodeBody(w)["item"].(map[string]interface{})
+		assert.Equal(t, float64(electronicsID), item["category_id"])
+
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Lost", "price": 5.0, "category_id": 9999}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should return a tree with item counts", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/categories", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		roots := DecodeBody(w)["categories"].([]interface{})
+		assert.Equal(t, 2, len(roots))
+		var electronics map[string]interface{}
+		for _, root := range roots {
+			if root.(map[string]interface{})["slug"] == "electronics" {
+				electronics = root.(map[string]interface{})
+			}
+		}
+		assert.Equal(t, float64(4), electronics["item_count"])
+		assert.Equal(t, float64(5), electronics["total_item_count"])
+		children := electronics["children"].([]interface{})
+		assert.Equal(t, 1, len(children))
+		assert.Equal(t, float64(1), children[0].(map[string]interface{})["item_count"])
+	})
+
+	t.Run("should rename, guard cycles and delete", func(t *testing.T) {
+		path := fmt.Sprintf("/categories/%d", electronicsID)
+		w := PerformRequest(router, "PUT", path, map[string]interface{}{"parent_id": audioID}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/categories/%d", audioID), map[string]interface{}{"name": "Sound"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var item models.Item
+		db.Where("name = ?", "Headphones").First(&item)
+		assert.Equal(t, "Sound", item.Category)
+
+		w = PerformRequest(router, "DELETE", path, nil, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = PerformRequest(router, "POST", "/categories", map[string]interface{}{"name": "Empty"}, adminToken, nil)
+		emptyID := uint(DecodeBody(w)["category"].(map[string]interface{})["id"].(float64))
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("/categories/%d", emptyID), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
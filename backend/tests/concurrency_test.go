@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"net/http"
+	"runtime"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestConcurrentAddToCart(t *testing.T) {
+	// :memory: allows a single connection, which would serialize the requests
+	router := SetupFileTestRouter(t.TempDir(), 8)
+	defer closeFileTestDB()
+
+	token := SignupAndLogin(router, "concurrencytest", "password123")
+
+	// Create the cart up front so every goroutine updates the same line
+	w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("should not lose quantity updates under concurrent requests", func(t *testing.T) {
+		const workers = 50
+
+		// Make sure requests really interleave even on a single CPU
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		codes := make(chan int, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+				codes <- w.Code
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(codes)
+
+		for code := range codes {
+			assert.Equal(t, http.StatusOK, code)
+		}
+
+		var cartItems []models.CartItem
+		err := testDB.Where("item_id = ?", 1).Find(&cartItems).Error
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(cartItems))
+		assert.Equal(t, workers+1, cartItems[0].Quantity)
+	})
+}
+
+func TestConcurrentFirstAddToCart(t *testing.T) {
+	router := SetupFileTestRouter(t.TempDir(), 8)
+	defer closeFileTestDB()
+
+	token := SignupAndLogin(router, "firstaddtest", "password123")
+	var user models.User
+	testDB.Where("username = ?", "firstaddtest").First(&user)
+
+	t.Run("should create one cart and one line for parallel first adds", func(t *testing.T) {
+		const workers = 20
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		codes := make(chan int, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+				codes <- w.Code
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(codes)
+
+		for code := range codes {
+			assert.Equal(t, http.StatusOK, code)
+		}
+
+		var carts []models.Cart
+		testDB.Where("user_id = ? AND status = ?", user.ID, "active").Find(&carts)
+		if assert.Len(t, carts, 1) {
+			var lines []models.CartItem
+			testDB.Where("cart_id = ?", carts[0].ID).Find(&lines)
+			if assert.Len(t, lines, 1) {
+				assert.Equal(t, workers, lines[0].Quantity)
+			}
+		}
+	})
+
+	t.Run("should reject duplicate active carts and lines", func(t *testing.T) {
+		var cart models.Cart
+		testDB.Where("user_id = ? AND status = ?", user.ID, "active").First(&cart)
+
+		err := testDB.Create(&models.Cart{UserID: user.ID, Status: "active"}).Error
+		assert.True(t, utils.IsUniqueViolation(err), err)
+		err = testDB.Create(&models.CartItem{CartID: cart.ID, ItemID: 1, Quantity: 1, Price: 1}).Error
+		assert.True(t, utils.IsUniqueViolation(err), err)
+
+		// Only active carts are limited to one per user
+		assert.NoError(t, testDB.Create(&models.Cart{UserID: user.ID, Status: "merged"}).Error)
+	})
+}
+
+func TestCheckoutDuringAddToCart(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "checkoutracetest", "password123")
+	PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+
+	t.Run("should not drop a line added after checkout read the cart", func(t *testing.T) {
+		// Another request adds an item once checkout has loaded the lines
+		added := false
+		testDB.Callback().Query().After("gorm:query").Register("test:add_during_checkout", func(tx *gorm.DB) {
+			if added || tx.Statement.Table != "cart_items" {
+				return
+			}
+			added = true
+			w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 2, "quantity": 1}, token, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		testDB.Callback().Query().Remove("test:add_during_checkout")
+		assert.True(t, added)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var count int64
+		testDB.Model(&models.Order{}).Count(&count)
+		assert.Zero(t, count)
+
+		// Checking out again orders both lines
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var lines []models.OrderItem
+		testDB.Where("order_id = ?", uint(DecodeBody(w)["order_id"].(float64))).Find(&lines)
+		assert.Len(t, lines, 2)
+	})
+}
+
+func closeFileTestDB() {
+	CleanupTestDB(testDB)
+	if sqlDB, err := testDB.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+func TestCartETag(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "etagtest", "password123")
+	PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+
+	t.Run("should return the cart version as an ETag", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/carts", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should accept an update with a current If-Match", func(t *testing.T) {
+		headers := map[string]string{"If-Match": `"1"`}
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 2, "quantity": 1}, token, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should reject an update with a stale If-Match", func(t *testing.T) {
+		headers := map[string]string{"If-Match": `"1"`}
+		w := PerformRequest(router, "DELETE", "/carts", map[string]interface{}{"item_id": 2}, token, headers)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestItemETag(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAdmin(router, testDB, "itemetagtest", "password123")
+
+	t.Run("should update an item with a current If-Match", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/items/1", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		etag := w.Header().Get("ETag")
+		assert.Equal(t, `"1"`, etag)
+
+		w = PerformRequest(router, "PUT", "/items/1", map[string]interface{}{"price": 12.5}, token, map[string]string{"If-Match": etag})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should reject an item update with a stale If-Match", func(t *testing.T) {
+		w := PerformRequest(router, "PUT", "/items/1", map[string]interface{}{"price": 13.5}, token, map[string]string{"If-Match": `"1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		var item models.Item
+		testDB.First(&item, 1)
+		assert.Equal(t, 12.5, item.Price)
+	})
+}
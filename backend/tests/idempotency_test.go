@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"shopping-cart/middlewares"
+	"shopping-cart/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "idemtest", "password123")
+	cartData := map[string]interface{}{
+		"item_id":  1,
+		"quantity": 2,
+	}
+
+	t.Run("should replay the stored response for a retried cart request", func(t *testing.T) {
+		headers := map[string]string{"Idempotency-Key": "cart-retry-1"}
+
+		first := PerformRequest(router, "POST", "/carts", cartData, token, headers)
+		assert.Equal(t, http.StatusOK, first.Code)
+
+		second := PerformRequest(router, "POST", "/carts", cartData, token, headers)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+
+		// Quantity must not have been doubled
+		var cartItem models.CartItem
+		err := testDB.Where("item_id = ?", 1).First(&cartItem).Error
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cartItem.Quantity)
+	})
+
+	t.Run("should reject reusing a key with a different body", func(t *testing.T) {
+		headers := map[string]string{"Idempotency-Key": "cart-retry-1"}
+		otherData := map[string]interface{}{
+			"item_id":  2,
+			"quantity": 1,
+		}
+
+		w := PerformRequest(router, "POST", "/carts", otherData, token, headers)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("should create only one order for a retried order request", func(t *testing.T) {
+		headers := map[string]string{"Idempotency-Key": "order-retry-1"}
+
+		first := PerformRequest(router, "POST", "/orders", nil, token, headers)
+		assert.Equal(t, http.StatusCreated, first.Code)
+
+		second := PerformRequest(router, "POST", "/orders", nil, token, headers)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+
+		var count int64
+		testDB.Model(&models.Order{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("should scope keys to the user", func(t *testing.T) {
+		otherToken := SignupAndLogin(router, "idemother", "password123")
+		headers := map[string]string{"Idempotency-Key": "cart-retry-1"}
+
+		w := PerformRequest(router, "POST", "/carts", cartData, otherToken, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("should release the key when the handler panics", func(t *testing.T) {
+		calls := 0
+		panicky := gin.New()
+		panicky.Use(gin.RecoveryWithWriter(io.Discard))
+		panicky.POST("/flaky", middlewares.IdempotencyMiddleware(), func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("handler failed")
+			}
+			c.JSON(http.StatusOK, gin.H{"calls": calls})
+		})
+		headers := map[string]string{"Idempotency-Key": "flaky-1"}
+
+		w := PerformRequest(panicky, "POST", "/flaky", nil, "", headers)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = PerformRequest(panicky, "POST", "/flaky", nil, "", headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(2), DecodeBody(w)["calls"])
+	})
+}
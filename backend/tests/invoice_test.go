@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOrderInvoices(t *testing.T) {
+	t.Setenv("INVOICE_NUMBER_PREFIX", "INV-")
+	t.Setenv("INVOICE_CURRENCY", "USD")
+	t.Setenv("INVOICE_TAX_NAME", "VAT")
+	t.Setenv("INVOICE_TAX_RATE", "0.20")
+	t.Setenv("SELLER_NAME", "Acme Stores")
+	t.Setenv("SELLER_ADDRESS", `1 Market Street\nSpringfield`)
+	t.Setenv("SELLER_TAX_ID", "GB999")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "invoiceadmin", "password123")
+	token := SignupAndLogin(router, "invoicebuyer", "password123")
+	otherToken := SignupAndLogin(router, "invoiceother", "password123")
+
+	placeOrder := func(body interface{}, itemID uint, quantity int) uint {
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": itemID, "quantity": quantity}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "POST", "/orders", body, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		return uint(DecodeBody(w)["order_id"].(float64))
+	}
+	setStatus := func(orderID uint, status string) {
+		w := PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", orderID), map[string]interface{}{"status": status}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	invoicePath := func(orderID uint) string {
+		return fmt.Sprintf("/orders/%d/invoice", orderID)
+	}
+
+	first := placeOrder(map[string]interface{}{
+		"billing_address":  "Jane Doe\n2 High Street",
+		"shipping_address": "Jane Doe\n9 Low Road",
+	}, 1, 2)
+	unpaid := placeOrder(nil, 2, 1)
+	cancelled := placeOrder(nil, 2, 1)
+	second := placeOrder(nil, 2, 1)
+
+	t.Run("should refuse an invoice before payment", func(t *testing.T) {
+		w := PerformRequest(router, "GET", invoicePath(unpaid), nil, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		setStatus(cancelled, "cancelled")
+		w = PerformRequest(router, "GET", invoicePath(cancelled), nil, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("should number invoices in payment order without gaps", func(t *testing.T) {
+		setStatus(second, "paid")
+		setStatus(first, "paid")
+		setStatus(first, "shipped")
+
+		var invoices []models.Invoice
+		db.Order("sequence").Find(&invoices)
+		if assert.Len(t, invoices, 2) {
+			assert.Equal(t, second, invoices[0].OrderID)
+			assert.Equal(t, "INV-000001", invoices[0].Number)
+			assert.Equal(t, first, invoices[1].OrderID)
+			assert.Equal(t, "INV-000002", invoices[1].Number)
+		}
+	})
+
+	t.Run("should split tax out of the line totals", func(t *testing.T) {
+		var invoice models.Invoice
+		db.Where("order_id = ?", first).First(&invoice)
+		// 2 x 10.99 = 21.98 including 20% VAT
+		assert.Equal(t, 18.32, invoice.Subtotal)
+		assert.Equal(t, 3.66, invoice.TaxAmount)
+		assert.Equal(t, 21.98, invoice.Total)
+		assert.Equal(t, "Acme Stores", invoice.SellerName)
+		assert.Equal(t, "1 Market Street\nSpringfield", invoice.SellerAddress)
+	})
+
+	t.Run("should render HTML with lines, tax and addresses", func(t *testing.T) {
+		w := PerformRequest(router, "GET", invoicePath(first), nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+		body := w.Body.String()
+		for _, text := range []string{"INV-000002", "Test Item 1", "18.32 USD", "3.66 USD", "21.98 USD", "VAT 20%", "Acme Stores", "Springfield", "GB999", "2 High Street", "9 Low Road"} {
+			assert.Contains(t, body, text)
+		}
+	})
+
+	t.Run("should render a PDF", func(t *testing.T) {
+		w := PerformRequest(router, "GET", invoicePath(first)+"?format=pdf", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="invoice-INV-000002.pdf"`, w.Header().Get("Content-Disposition"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "%PDF-"))
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(w.Body.String()), "%%EOF"))
+
+		w = PerformRequest(router, "GET", invoicePath(first)+"?format=docx", nil, token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should keep invoices private to the owner and admins", func(t *testing.T) {
+		w := PerformRequest(router, "GET", invoicePath(first), nil, otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = PerformRequest(router, "GET", invoicePath(first), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", invoicePath(first), nil, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should keep the issued invoice when settings change", func(t *testing.T) {
+		t.Setenv("SELLER_NAME", "Renamed Stores")
+		w := PerformRequest(router, "GET", invoicePath(first), nil, token, nil)
+		assert.Contains(t, w.Body.String(), "Acme Stores")
+		assert.NotContains(t, w.Body.String(), "Renamed Stores")
+	})
+
+	t.Run("should list the invoice with the order", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		numbers := map[float64]interface{}{}
+		for _, entry := range DecodeBody(w)["orders"].([]interface{}) {
+			order := entry.(map[string]interface{})
+			if invoice, ok := order["invoice"].(map[string]interface{}); ok {
+				numbers[order["id"].(float64)] = invoice["number"]
+			}
+		}
+		assert.Equal(t, map[float64]interface{}{float64(first): "INV-000002", float64(second): "INV-000001"}, numbers)
+	})
+
+	t.Run("should backfill invoices of orders paid before invoices existed", func(t *testing.T) {
+		// Marked paid without going through the API, the later one first
+		later := placeOrder(nil, 1, 1)
+		earlier := placeOrder(nil, 2, 1)
+		now := time.Now()
+		db.Model(&models.Order{}).Where("id = ?", later).UpdateColumns(map[string]interface{}{"status": models.OrderDelivered, "updated_at": now.Add(-time.Hour)})
+		db.Model(&models.Order{}).Where("id = ?", earlier).UpdateColumns(map[string]interface{}{"status": models.OrderPaid, "updated_at": now.Add(-2 * time.Hour)})
+
+		w := PerformRequest(router, "GET", invoicePath(earlier), nil, token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		assert.NoError(t, utils.BackfillInvoices(db, now))
+		var earlierInvoice, laterInvoice models.Invoice
+		db.Where("order_id = ?", earlier).First(&earlierInvoice)
+		assert.Equal(t, "INV-000003", earlierInvoice.Number)
+		db.Where("order_id = ?", later).First(&laterInvoice)
+		assert.Equal(t, "INV-000004", laterInvoice.Number)
+
+		w = PerformRequest(router, "GET", invoicePath(earlier), nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Running it again issues nothing new
+		assert.NoError(t, utils.BackfillInvoices(db, now))
+		var count int64
+		db.Model(&models.Invoice{}).Count(&count)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("should retry when a concurrent payment takes the number", func(t *testing.T) {
+		orderID := placeOrder(nil, 1, 1)
+		var last uint
+		db.Model(&models.Invoice{}).Select("COALESCE(MAX(sequence), 0)").Scan(&last)
+
+		// Another payment takes the next number between reading the
+		// highest sequence and inserting this invoice
+		taken := false
+		db.Callback().Create().Before("gorm:create").Register("test:take_invoice_number", func(tx *gorm.DB) {
+			if taken || tx.Statement.Table != "invoices" {
+				return
+			}
+			taken = true
+			tx.Session(&gorm.Session{NewDB: true}).Exec("INSERT INTO invoices (order_id, sequence, number, issued_at, currency, subtotal, tax_amount, total) VALUES (0, ?, 'TAKEN', ?, 'USD', 0, 0, 0)",
+				last+1, time.Now())
+		})
+		defer db.Callback().Create().Remove("test:take_invoice_number")
+
+		setStatus(orderID, "paid")
+		assert.True(t, taken)
+
+		var invoice models.Invoice
+		assert.NoError(t, db.Where("order_id = ?", orderID).First(&invoice).Error)
+		assert.Equal(t, last+1, invoice.Sequence)
+	})
+}
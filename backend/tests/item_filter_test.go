@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemFacets(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "facetadmin", "password123")
+
+	createCategory := func(body map[string]interface{}) uint {
+		w := PerformRequest(router, "POST", "/categories", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		return uint(DecodeBody(w)["category"].(map[string]interface{})["id"].(float64))
+	}
+	audioID := createCategory(map[string]interface{}{"name": "Audio"})
+	headphonesID := createCategory(map[string]interface{}{"name": "Headphones", "parent_id": audioID})
+
+	w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Speaker", "price": 60.0, "category_id": audioID}, adminToken, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	speakerID := uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+	w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Earbuds", "price": 30.0, "category_id": headphonesID}, adminToken, nil)
+	earbudsID := uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+	PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", earbudsID), map[string]interface{}{"in_stock": false}, adminToken, nil)
+
+	list := func(query string) map[string]interface{} {
+		w := PerformRequest(router, "GET", "/items"+query, nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return DecodeBody(w)
+	}
+	itemIDs := func(body map[string]interface{}) []uint {
+		var ids []uint
+		for _, item := range body["items"].([]interface{}) {
+			ids = append(ids, uint(item.(map[string]interface{})["id"].(float64)))
+		}
+		return ids
+	}
+	facets := func(body map[string]interface{}) map[string]interface{} {
+		return body["facets"].(map[string]interface{})
+	}
+
+	t.Run("should filter by category including subcategories", func(t *testing.T) {
+		body := list(fmt.Sprintf("?category_id=%d", audioID))
+		assert.ElementsMatch(t, []uint{speakerID, earbudsID}, itemIDs(body))
+		assert.Equal(t, float64(2), body["total"])
+	})
+
+	t.Run("should leave a facet's own filter out of its counts", func(t *testing.T) {
+		body := list(fmt.Sprintf("?category_id=%d&in_stock=true", audioID))
+		assert.Equal(t, []uint{speakerID}, itemIDs(body))
+		availability := facets(body)["availability"].(map[string]interface{})
+		assert.Equal(t, float64(1), availability["in_stock"])
+		assert.Equal(t, float64(1), availability["out_of_stock"])
+	})
+
+	t.Run("should count price buckets", func(t *testing.T) {
+		body := list("?min_price=15&max_price=40")
+		assert.ElementsMatch(t, []uint{2, earbudsID}, itemIDs(body))
+
+		prices := facets(body)["prices"].([]interface{})
+		assert.Len(t, prices, 6)
+		counts := []float64{}
+		for _, bucket := range prices {
+			counts = append(counts, bucket.(map[string]interface{})["count"].(float64))
+		}
+		assert.Equal(t, []float64{0, 2, 1, 1, 0, 0}, counts)
+		assert.Nil(t, prices[5].(map[string]interface{})["max"])
+
+		// Other facets do apply the price filter
+		availability := facets(body)["availability"].(map[string]interface{})
+		assert.Equal(t, float64(1), availability["in_stock"])
+		assert.Equal(t, float64(1), availability["out_of_stock"])
+	})
+
+	t.Run("should count rating buckets", func(t *testing.T) {
+		body := list("?min_rating=4.2")
+		assert.Equal(t, []uint{1}, itemIDs(body))
+		ratings := facets(body)["ratings"].([]interface{})
+		assert.Equal(t, float64(4), ratings[0].(map[string]interface{})["min_rating"])
+		assert.Equal(t, float64(2), ratings[0].(map[string]interface{})["count"])
+	})
+
+	t.Run("should count categories in a tree", func(t *testing.T) {
+		categories := facets(list("?min_price=50"))["categories"].([]interface{})
+		assert.Len(t, categories, 1)
+		audio := categories[0].(map[string]interface{})
+		assert.Equal(t, float64(1), audio["item_count"])
+		assert.Equal(t, float64(1), audio["total_item_count"])
+		assert.Equal(t, float64(0), audio["children"].([]interface{})[0].(map[string]interface{})["total_item_count"])
+
+		categories = facets(list(fmt.Sprintf("?category_id=%d", headphonesID)))["categories"].([]interface{})
+		assert.Equal(t, float64(2), categories[0].(map[string]interface{})["total_item_count"])
+	})
+
+	t.Run("should sort and paginate", func(t *testing.T) {
+		body := list("?sort=price_desc&page=1&page_size=2")
+		assert.Equal(t, []uint{speakerID, earbudsID}, itemIDs(body))
+		assert.Equal(t, float64(4), body["total"])
+		assert.Equal(t, float64(2), body["page_size"])
+
+		body = list("?sort=price_desc&page=2&page_size=2")
+		assert.Equal(t, []uint{2, 1}, itemIDs(body))
+
+		assert.Len(t, list("")["items"], 4)
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, PerformRequest(router, "GET", "/items?min_price=cheap", nil, "", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, PerformRequest(router, "GET", "/items?in_stock=maybe", nil, "", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, PerformRequest(router, "GET", "/items?category_id=x", nil, "", nil).Code)
+	})
+}
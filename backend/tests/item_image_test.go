@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"shopping-cart/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadImage posts a file as the image field of a multipart form
+func uploadImage(router *gin.Engine, token string, itemID uint, filename string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", filename)
+	part.Write(data)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/items/%d/images", itemID), &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func testPNG(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x%height, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestItemImages(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	dir := UseTestStorage(t)
+	t.Setenv("IMAGE_THUMBNAIL_SIZES", "100,400")
+	t.Setenv("IMAGE_MAX_UPLOAD_MB", "1")
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "imageadmin", "password123")
+	customerToken := SignupAndLogin(router, "imagecustomer", "password123")
+
+	var first, second map[string]interface{}
+
+	t.Run("should upload images with thumbnails", func(t *testing.T) {
+		w := uploadImage(router, customerToken, 1, "a.png", testPNG(800, 600))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = uploadImage(router, adminToken, 1, "a.png", testPNG(800, 600))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		first = DecodeBody(w)["image"].(map[string]interface{})
+		assert.Equal(t, "image/png", first["content_type"])
+		assert.Equal(t, float64(800), first["width"])
+		thumbnails := first["thumbnails"].(map[string]interface{})
+		assert.Equal(t, 2, len(thumbnails))
+
+		// The 100px thumbnail keeps the aspect ratio
+		key := strings.TrimPrefix(thumbnails["100"].(string), "/uploads/")
+		file, err := os.Open(filepath.Join(dir, key))
+		assert.NoError(t, err)
+		config, _, err := image.DecodeConfig(file)
+		file.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, 100, config.Width)
+		assert.Equal(t, 75, config.Height)
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, first["url"], item.Image)
+
+		w = uploadImage(router, adminToken, 1, "b.png", testPNG(50, 50))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		second = DecodeBody(w)["image"].(map[string]interface{})
+		assert.Equal(t, float64(1), second["position"])
+	})
+
+	t.Run("should check type and size", func(t *testing.T) {
+		// The declared name does not matter, the contents do
+		w := uploadImage(router, adminToken, 1, "fake.png", []byte("<html>not an image</html>"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		w = uploadImage(router, adminToken, 1, "big.png", bytes.Repeat([]byte{0x89}, 2<<20))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+		w = uploadImage(router, adminToken, 999, "a.png", testPNG(10, 10))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should serve uploads with cache headers", func(t *testing.T) {
+		w := PerformRequest(router, "GET", first["url"].(string), nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Cache-Control"), "immutable")
+
+		w = PerformRequest(router, "GET", "/uploads/../go.mod", nil, "", nil)
+		assert.NotEqual(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "GET", "/uploads/items/1/missing.png", nil, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should reorder and delete images", func(t *testing.T) {
+		order := map[string]interface{}{"image_ids": []interface{}{second["id"], first["id"]}}
+		w := PerformRequest(router, "PUT", "/items/1/images/order", order, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, second["url"], item.Image)
+
+		w = PerformRequest(router, "PUT", "/items/1/images/order", map[string]interface{}{"image_ids": []interface{}{first["id"]}}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("/items/1/images/%d", uint(second["id"].(float64))), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/items/1/images", nil, "", nil)
+		images := DecodeBody(w)["images"].([]interface{})
+		assert.Equal(t, 1, len(images))
+		assert.Equal(t, float64(0), images[0].(map[string]interface{})["position"])
+
+		db.First(&item, 1)
+		assert.Equal(t, first["url"], item.Image)
+
+		_, err := os.Stat(filepath.Join(dir, strings.TrimPrefix(second["url"].(string), "/uploads/")))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemStatus(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "statusadmin", "password123")
+	token := SignupAndLogin(router, "statuscustomer", "password123")
+
+	listIDs := func(token string) map[uint]bool {
+		w := PerformRequest(router, "GET", "/items", nil, token, nil)
+		ids := map[uint]bool{}
+		for _, item := range DecodeBody(w)["items"].([]interface{}) {
+			ids[uint(item.(map[string]interface{})["id"].(float64))] = true
+		}
+		return ids
+	}
+
+	var draftID, archivedID, scheduledID uint
+
+	t.Run("should hide drafts from customers", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Draft", "price": 5.0, "status": "draft"}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		draftID = uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Bad", "price": 5.0, "status": "hidden"}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		assert.False(t, listIDs("")[draftID])
+		assert.False(t, listIDs(token)[draftID])
+		assert.True(t, listIDs(adminToken)[draftID])
+
+		path := fmt.Sprintf("/items/%d", draftID)
+		assert.Equal(t, http.StatusNotFound, PerformRequest(router, "GET", path, nil, "", nil).Code)
+		assert.Equal(t, http.StatusOK, PerformRequest(router, "GET", path, nil, adminToken, nil).Code)
+
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": draftID, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should only let admins change items", func(t *testing.T) {
+		path := fmt.Sprintf("/items/%d", draftID)
+		w := PerformRequest(router, "PUT", path, map[string]interface{}{"status": "active"}, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = PerformRequest(router, "PUT", "/items/1", map[string]interface{}{"name": "Renamed"}, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = PerformRequest(router, "DELETE", "/items/1", nil, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Mine", "price": 1.0, "category": "New"}, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var draft models.Item
+		db.First(&draft, draftID)
+		assert.Equal(t, models.ItemDraft, draft.Status)
+		var count int64
+		db.Model(&models.Category{}).Where("name = ?", "New").Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("should keep archived items resolvable but not purchasable", func(t *testing.T) {
+		// Put the item in an order and the cart before archiving it
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 2, "quantity": 1}, token, nil)
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		archivedID = 2
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": archivedID, "quantity": 1}, token, nil)
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", archivedID), map[string]interface{}{"status": "archived"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.False(t, listIDs("")[archivedID])
+		w = PerformRequest(router, "GET", fmt.Sprintf("/items/%d", archivedID), nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "archived", DecodeBody(w)["item"].(map[string]interface{})["status"])
+
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": archivedID, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// The line added before archiving blocks checkout
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = PerformRequest(router, "GET", "/orders", nil, token, nil)
+		orders := DecodeBody(w)["orders"].([]interface{})
+		line := orders[0].(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, float64(archivedID), line["item_id"])
+	})
+
+	t.Run("should follow publish and unpublish times", func(t *testing.T) {
+		publishAt := time.Now().Add(time.Hour)
+		body := map[string]interface{}{"name": "Launch", "price": 9.0, "publish_at": publishAt}
+		w := PerformRequest(router, "POST", "/items", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		scheduledID = uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+		assert.False(t, listIDs("")[scheduledID])
+
+		// Move the publish time into the past
+		db.Model(&models.Item{}).Where("id = ?", scheduledID).Update("publish_at", time.Now().Add(-time.Minute))
+		assert.True(t, listIDs("")[scheduledID])
+
+		db.Model(&models.Item{}).Where("id = ?", scheduledID).Update("unpublish_at", time.Now().Add(-time.Second))
+		assert.False(t, listIDs("")[scheduledID])
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", scheduledID), map[string]interface{}{"publish_at": time.Now().Add(2 * time.Hour), "unpublish_at": time.Now().Add(time.Hour)}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should store scheduled status changes", func(t *testing.T) {
+		db.Model(&models.Item{}).Where("id = ?", draftID).Update("publish_at", time.Now().Add(-time.Minute))
+		w := PerformRequest(router, "GET", "/search/suggest?q=draf", nil, "", nil)
+		assert.Empty(t, DecodeBody(w)["suggestions"])
+
+		ids, err := utils.ApplyItemSchedules(db, time.Now())
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint{draftID, scheduledID}, ids)
+		assert.NoError(t, utils.IndexItems(db, ids...))
+
+		// The published draft is suggested once reindexed
+		w = PerformRequest(router, "GET", "/search/suggest?q=draf", nil, "", nil)
+		assert.Equal(t, []interface{}{"draft"}, DecodeBody(w)["suggestions"])
+
+		var draft, scheduled models.Item
+		db.First(&draft, draftID)
+		db.First(&scheduled, scheduledID)
+		assert.Equal(t, models.ItemActive, draft.Status)
+		assert.Equal(t, models.ItemArchived, scheduled.Status)
+	})
+}
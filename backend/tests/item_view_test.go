@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentlyViewed(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "viewadmin", "password123")
+	token := SignupAndLogin(router, "viewer", "password123")
+
+	viewedIDs := func(token string, headers map[string]string) []uint {
+		w := PerformRequest(router, "GET", "/users/me/recently-viewed", nil, token, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var ids []uint
+		for _, entry := range DecodeBody(w)["items"].([]interface{}) {
+			ids = append(ids, uint(entry.(map[string]interface{})["item"].(map[string]interface{})["id"].(float64)))
+		}
+		return ids
+	}
+
+	t.Run("should list a user's views newest first", func(t *testing.T) {
+		PerformRequest(router, "GET", "/items/1", nil, token, nil)
+		PerformRequest(router, "GET", "/items/2", nil, token, nil)
+		assert.Equal(t, []uint{2, 1}, viewedIDs(token, nil))
+
+		PerformRequest(router, "GET", "/items/1", nil, token, nil)
+		assert.Equal(t, []uint{1, 2}, viewedIDs(token, nil))
+	})
+
+	t.Run("should give guests a token on request", func(t *testing.T) {
+		var before int64
+		db.Model(&models.GuestToken{}).Count(&before)
+
+		// Viewing without a token hands none out
+		w := PerformRequest(router, "GET", "/items/2", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Guest-Token"))
+		var after int64
+		db.Model(&models.GuestToken{}).Count(&after)
+		assert.Equal(t, before, after)
+
+		w = PerformRequest(router, "POST", "/guest-token", nil, "", nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		guestToken := DecodeBody(w)["guest_token"].(string)
+		assert.Equal(t, guestToken, w.Header().Get("X-Guest-Token"))
+
+		headers := map[string]string{"X-Guest-Token": guestToken}
+		assert.Empty(t, viewedIDs("", headers))
+
+		PerformRequest(router, "GET", "/items/2", nil, "", headers)
+		w = PerformRequest(router, "GET", "/items/1", nil, "", headers)
+		assert.Empty(t, w.Header().Get("X-Guest-Token"))
+		assert.Equal(t, []uint{1, 2}, viewedIDs("", headers))
+
+		w = PerformRequest(router, "GET", "/users/me/recently-viewed", nil, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, viewedIDs("", map[string]string{"X-Guest-Token": "someone-else"}))
+	})
+
+	t.Run("should keep a bounded history", func(t *testing.T) {
+		t.Setenv("RECENTLY_VIEWED_LIMIT", "3")
+		var created []uint
+		for i := 0; i < 3; i++ {
+			w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": fmt.Sprintf("Viewed %d", i), "price": 1.0}, adminToken, nil)
+			id := uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+			created = append(created, id)
+			PerformRequest(router, "GET", fmt.Sprintf("/items/%d", id), nil, token, nil)
+		}
+		assert.Equal(t, []uint{created[2], created[1], created[0]}, viewedIDs(token, nil))
+
+		var count int64
+		db.Model(&models.ItemView{}).Where("user_id IS NOT NULL").Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("should sort items by trending", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/items?sort=trending", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		items := DecodeBody(w)["items"].([]interface{})
+		// Item 1 and 2 were each seen by the user and a guest; repeat views don't count
+		assert.Equal(t, float64(1), items[0].(map[string]interface{})["id"])
+		assert.Equal(t, float64(2), items[1].(map[string]interface{})["id"])
+
+		// Views older than the window no longer count
+		utils.RecordItemView(db, 0, "old-guest", 2, time.Now().AddDate(0, 0, -30))
+		utils.RecordItemView(db, 0, "old-guest-2", 2, time.Now().AddDate(0, 0, -30))
+		items = DecodeBody(PerformRequest(router, "GET", "/items?sort=trending", nil, "", nil))["items"].([]interface{})
+		assert.Equal(t, float64(1), items[0].(map[string]interface{})["id"])
+
+		// A fresh view from a new guest puts item 2 ahead
+		utils.RecordItemView(db, 0, "new-guest", 2, time.Now())
+		items = DecodeBody(PerformRequest(router, "GET", "/items?sort=trending", nil, "", nil))["items"].([]interface{})
+		assert.Equal(t, float64(2), items[0].(map[string]interface{})["id"])
+
+		w = PerformRequest(router, "GET", "/items?sort=unknown", nil, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should ignore views without a token or with an unknown one", func(t *testing.T) {
+		var before int64
+		db.Model(&models.ItemViewCount{}).Select("COALESCE(SUM(views), 0)").Scan(&before)
+
+		for i := 0; i < 3; i++ {
+			PerformRequest(router, "GET", "/items/2", nil, "", nil)
+			PerformRequest(router, "GET", "/items/2", nil, "", map[string]string{"X-Guest-Token": fmt.Sprintf("made-up-%d", i)})
+		}
+
+		var after int64
+		db.Model(&models.ItemViewCount{}).Select("COALESCE(SUM(views), 0)").Scan(&after)
+		assert.Equal(t, before, after)
+		assert.Empty(t, viewedIDs("", map[string]string{"X-Guest-Token": "made-up-0"}))
+	})
+
+	t.Run("should remove the history of expired guest tokens", func(t *testing.T) {
+		t.Setenv("GUEST_VIEW_TTL_DAYS", "30")
+		guestToken := DecodeBody(PerformRequest(router, "POST", "/guest-token", nil, "", nil))["guest_token"].(string)
+		headers := map[string]string{"X-Guest-Token": guestToken}
+		PerformRequest(router, "GET", "/items/1", nil, "", headers)
+		assert.Equal(t, []uint{1}, viewedIDs("", headers))
+
+		var counted int64
+		db.Model(&models.ItemViewCount{}).Select("COALESCE(SUM(views), 0)").Scan(&counted)
+
+		// A recent guest survives the cleanup
+		assert.NoError(t, utils.CleanupGuestViews(db, time.Now()))
+		assert.Equal(t, []uint{1}, viewedIDs("", headers))
+
+		assert.NoError(t, utils.CleanupGuestViews(db, time.Now().AddDate(0, 0, 31)))
+		assert.Empty(t, viewedIDs("", headers))
+		assert.NotEmpty(t, viewedIDs(token, nil))
+
+		// The token is forgotten, so its views are no longer counted
+		PerformRequest(router, "GET", "/items/2", nil, "", headers)
+		assert.Empty(t, viewedIDs("", headers))
+
+		var after int64
+		db.Model(&models.ItemViewCount{}).Select("COALESCE(SUM(views), 0)").Scan(&after)
+		assert.Equal(t, counted, after)
+	})
+
+	t.Run("should remove guest tokens that were never used", func(t *testing.T) {
+		t.Setenv("GUEST_TOKEN_UNUSED_TTL_HOURS", "24")
+		unused := DecodeBody(PerformRequest(router, "POST", "/guest-token", nil, "", nil))["guest_token"].(string)
+		used := DecodeBody(PerformRequest(router, "POST", "/guest-token", nil, "", nil))["guest_token"].(string)
+		PerformRequest(router, "GET", "/items/1", nil, "", map[string]string{"X-Guest-Token": used})
+
+		assert.NoError(t, utils.CleanupGuestViews(db, time.Now().Add(25*time.Hour)))
+		var count int64
+		db.Model(&models.GuestToken{}).Where("token_hash = ?", utils.HashToken(unused)).Count(&count)
+		assert.Zero(t, count)
+		db.Model(&models.GuestToken{}).Where("token_hash = ?", utils.HashToken(used)).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
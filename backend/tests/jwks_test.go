@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"shopping-cart/utils"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePrivateKey stores a private key as <kid>.pem in dir
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &utils.Claims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestAsymmetricJWTKeys(t *testing.T) {
+	keysDir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePrivateKey(t, keysDir, "rsa-2024", rsaKey)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, keysDir, "ed-2025", edKey)
+
+	t.Setenv("JWT_ALGORITHM", "RS256")
+	t.Setenv("JWT_KEYS_DIR", keysDir)
+	t.Setenv("JWT_SIGNING_KEY_ID", "rsa-2024")
+	utils.ResetJWTKeys()
+	t.Cleanup(utils.ResetJWTKeys)
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	rsaToken := SignupAndLogin(router, "jwkstest", "password123")
+
+	t.Run("should sign with RS256 and a kid header", func(t *testing.T) {
+		assert.Equal(t, "rsa-2024", tokenKeyID(t, rsaToken))
+
+		w := PerformRequest(router, "GET", "/carts", nil, rsaToken, nil)
+		assert.NotEqual(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should publish every verification key", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/.well-known/jwks.json", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		keys := DecodeBody(w)["keys"].([]interface{})
+		assert.Equal(t, 2, len(keys))
+		assert.Equal(t, "ed-2025", keys[0].(map[string]interface{})["kid"])
+		assert.Equal(t, "OKP", keys[0].(map[string]interface{})["kty"])
+		assert.Equal(t, "rsa-2024", keys[1].(map[string]interface{})["kid"])
+		assert.Equal(t, "RSA", keys[1].(map[string]interface{})["kty"])
+	})
+
+	t.Run("should keep old tokens valid after rotating to EdDSA", func(t *testing.T) {
+		t.Setenv("JWT_ALGORITHM", "EdDSA")
+		t.Setenv("JWT_SIGNING_KEY_ID", "ed-2025")
+		utils.ResetJWTKeys()
+
+		w := PerformRequest(router, "POST", "/users/login", map[string]interface{}{"username": "jwkstest", "password": "password123"}, "", nil)
+		edToken := DecodeBody(w)["token"].(string)
+		assert.Equal(t, "ed-2025", tokenKeyID(t, edToken))
+
+		w = PerformRequest(router, "GET", "/users/me", nil, edToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "GET", "/users/me", nil, rsaToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Retiring the old key invalidates the tokens it signed
+		os.Remove(filepath.Join(keysDir, "rsa-2024.pem"))
+		utils.ResetJWTKeys()
+
+		w = PerformRequest(router, "GET", "/users/me", nil, rsaToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = PerformRequest(router, "GET", "/users/me", nil, edToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestJWTReleaseModeSecret(t *testing.T) {
+	t.Cleanup(utils.ResetJWTKeys)
+
+	t.Run("should refuse the default secret in release mode", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "")
+		utils.ResetJWTKeys()
+
+		assert.Error(t, utils.ValidateJWTConfig(true))
+		assert.NoError(t, utils.ValidateJWTConfig(false))
+	})
+
+	t.Run("should accept a custom secret in release mode", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "a-real-production-secret")
+		utils.ResetJWTKeys()
+
+		assert.NoError(t, utils.ValidateJWTConfig(true))
+	})
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminMetrics(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "metricsadmin", "password123")
+	firstToken := SignupAndLogin(router, "metricsbuyer1", "password123")
+	secondToken := SignupAndLogin(router, "metricsbuyer2", "password123")
+
+	yesterday := utils.PeriodStart(time.Now(), utils.PeriodDay).AddDate(0, 0, -1)
+	at := func(hour, minute int) time.Time {
+		return yesterday.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	db.Model(&models.User{}).Where("username = ?", "metricsbuyer1").UpdateColumn("created_at", at(10, 0))
+	db.Model(&models.User{}).Where("username = ?", "metricsbuyer2").UpdateColumn("created_at", at(11, 0))
+
+	addToCart := func(token string, startedAt time.Time) {
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		db.Model(&models.CartSession{}).Where("order_id IS NULL AND started_at > ?", yesterday.AddDate(0, 0, 1)).UpdateColumn("started_at", startedAt)
+	}
+	placeOrder := func(token string, placedAt time.Time) uint {
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		id := uint(DecodeBody(w)["order_id"].(float64))
+		db.Model(&models.Order{}).Where("id = ?", id).UpdateColumn("created_at", placedAt)
+		return id
+	}
+	setStatus := func(orderID uint, status string) {
+		w := PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", orderID), map[string]interface{}{"status": status}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	getMetrics := func(query url.Values) map[string]interface{} {
+		w := PerformRequest(router, "GET", "/admin/metrics?"+query.Encode(), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return DecodeBody(w)
+	}
+	yesterdayOnly := url.Values{"from": {yesterday.Format("2006-01-02")}, "to": {yesterday.Format("2006-01-02")}}
+
+	// The first buyer checks out and starts again, the second only browses
+	addToCart(firstToken, at(10, 30))
+	addToCart(firstToken, at(10, 30))
+	paid := placeOrder(firstToken, at(10, 45))
+	setStatus(paid, models.OrderPaid)
+	addToCart(firstToken, at(14, 0))
+	addToCart(secondToken, at(11, 30))
+
+	t.Run("should start one cart session per trip through the cart", func(t *testing.T) {
+		var sessions []models.CartSession
+		db.Order("started_at").Find(&sessions)
+		if assert.Len(t, sessions, 3) {
+			assert.Equal(t, paid, *sessions[0].OrderID)
+			assert.Nil(t, sessions[1].OrderID)
+			assert.Nil(t, sessions[2].OrderID)
+		}
+	})
+
+	t.Run("should read from the rollups", func(t *testing.T) {
+		series := getMetrics(yesterdayOnly)["series"].([]interface{})
+		if assert.Len(t, series, 1) {
+			assert.Equal(t, float64(0), series[0].(map[string]interface{})["orders"])
+		}
+	})
+
+	assert.NoError(t, utils.RollupMetrics(db, time.Now()))
+
+	t.Run("should report a day", func(t *testing.T) {
+		response := getMetrics(yesterdayOnly)
+		assert.Equal(t, "day", response["granularity"])
+		series := response["series"].([]interface{})
+		if assert.Len(t, series, 1) {
+			day := series[0].(map[string]interface{})
+			assert.Equal(t, yesterday.Format(time.RFC3339), day["period_start"])
+			assert.Equal(t, float64(1), day["orders"])
+			assert.Equal(t, 21.98, day["revenue"])
+			assert.Equal(t, float64(2), day["signups"])
+			assert.Equal(t, float64(3), day["carts_created"])
+			assert.Equal(t, float64(1), day["carts_converted"])
+			assert.Equal(t, 0.3333, day["conversion_rate"])
+		}
+		assert.Equal(t, float64(2), response["totals"].(map[string]interface{})["signups"])
+	})
+
+	t.Run("should report hours", func(t *testing.T) {
+		response := getMetrics(url.Values{
+			"granularity": {"hour"},
+			"from":        {at(10, 0).Format(time.RFC3339)},
+			"to":          {at(12, 0).Format(time.RFC3339)},
+		})
+		series := response["series"].([]interface{})
+		if assert.Len(t, series, 2) {
+			ten := series[0].(map[string]interface{})
+			assert.Equal(t, float64(1), ten["orders"])
+			assert.Equal(t, float64(1), ten["signups"])
+			assert.Equal(t, float64(1), ten["conversion_rate"])
+			eleven := series[1].(map[string]interface{})
+			assert.Equal(t, float64(0), eleven["orders"])
+			assert.Equal(t, float64(1), eleven["carts_created"])
+			assert.Equal(t, float64(0), eleven["conversion_rate"])
+		}
+		assert.Equal(t, 0.5, response["totals"].(map[string]interface{})["conversion_rate"])
+	})
+
+	t.Run("should roll up recent periods again", func(t *testing.T) {
+		// A pending order converts the cart but isn't revenue yet
+		placeOrder(secondToken, at(11, 40))
+		assert.NoError(t, utils.RollupMetrics(db, time.Now()))
+
+		day := getMetrics(yesterdayOnly)["series"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, float64(2), day["orders"])
+		assert.Equal(t, 21.98, day["revenue"])
+		assert.Equal(t, float64(2), day["carts_converted"])
+		assert.Equal(t, 0.6667, day["conversion_rate"])
+	})
+
+	t.Run("should sum days into months", func(t *testing.T) {
+		response := getMetrics(url.Values{"granularity": {"month"}, "from": {yesterday.Format("2006-01-02")}, "to": {yesterday.Format("2006-01-02")}})
+		series := response["series"].([]interface{})
+		if assert.Len(t, series, 1) {
+			month := series[0].(map[string]interface{})
+			assert.Equal(t, utils.PeriodStart(yesterday, utils.PeriodMonth).Format(time.RFC3339), month["period_start"])
+			assert.Equal(t, float64(2), month["orders"])
+		}
+	})
+
+	t.Run("should leave periods before the lookback alone", func(t *testing.T) {
+		t.Setenv("METRICS_ROLLUP_LOOKBACK_DAYS", "0")
+		var pending models.Order
+		db.Where("status = ?", models.OrderPending).First(&pending)
+		setStatus(pending.ID, models.OrderPaid)
+		assert.NoError(t, utils.RollupMetrics(db, time.Now()))
+
+		day := getMetrics(yesterdayOnly)["series"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, 21.98, day["revenue"])
+	})
+
+	t.Run("should reject bad ranges", func(t *testing.T) {
+		for _, query := range []string{"granularity=year", "from=soon", "from=2026-02-01&to=2026-01-01", "granularity=hour&from=2020-01-01&to=2026-01-01"} {
+			w := PerformRequest(router, "GET", "/admin/metrics?"+query, nil, adminToken, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+
+		w := PerformRequest(router, "GET", "/admin/metrics", nil, firstToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"shopping-cart/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider for tests. Codes
+// are registered up front together with the identity they log in as.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	grants map[string]mockOIDCGrant
+}
+
+type mockOIDCGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	provider := &mockOIDCProvider{key: key, grants: map[string]mockOIDCGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.server.URL,
+			"authorization_endpoint": provider.server.URL + "/authorize",
+			"token_endpoint":         provider.server.URL + "/token",
+			"jwks_uri":               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock-key",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		provider.mu.Lock()
+		grant, ok := provider.grants[r.PostForm.Get("code")]
+		delete(provider.grants, r.PostForm.Get("code"))
+		provider.mu.Unlock()
+
+		// Enforce PKCE like a real provider would
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   provider.server.URL,
+			"aud":   r.PostForm.Get("client_id"),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": grant.nonce,
+		}
+		for k, v := range grant.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock-key"
+		idToken, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "mock", "token_type": "Bearer", "id_token": idToken})
+	})
+
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+// authorize plays the provider's login page: it accepts the authorization
+// URL and returns the callback query the browser would be sent back with
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	code := "code-" + query.Get("state")[:8]
+	p.mu.Lock()
+	p.grants[code] = mockOIDCGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: claims}
+	p.mu.Unlock()
+
+	return "code=" + url.QueryEscape(code) + "&state=" + url.QueryEscape(query.Get("state"))
+}
+
+// startOIDCLogin begins a login and returns the callback query together
+// with the state cookie the browser would send along
+func startOIDCLogin(t *testing.T, router *gin.Engine, provider *mockOIDCProvider, claims jwt.MapClaims) (string, map[string]string) {
+	w := PerformRequest(router, "GET", "/auth/oidc/login", nil, "", nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	headers := map[string]string{}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "oidc_state" {
+			assert.True(t, cookie.HttpOnly)
+			headers["Cookie"] = cookie.Name + "=" + cookie.Value
+		}
+	}
+	return provider.authorize(t, w.Header().Get("Location"), claims), headers
+}
+
+func oidcLogin(t *testing.T, router *gin.Engine, provider *mockOIDCProvider, claims jwt.MapClaims) map[string]interface{} {
+	callback, cookies := startOIDCLogin(t, router, provider, claims)
+	w := PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", cookies)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return DecodeBody(w)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	t.Setenv("OIDC_ISSUER", provider.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", "shopping-cart")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	t.Run("should create a user on first login and reuse it afterwards", func(t *testing.T) {
+		claims := jwt.MapClaims{"sub": "ext-1", "email": "new@example.com", "email_verified": true, "preferred_username": "newbie"}
+
+		response := oidcLogin(t, router, provider, claims)
+		token := response["token"].(string)
+		userID := response["user_id"]
+
+		w := PerformRequest(router, "GET", "/users/me", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		user := DecodeBody(w)["user"].(map[string]interface{})
+		assert.Equal(t, "newbie", user["username"])
+		assert.Equal(t, true, user["email_verified"])
+
+		response = oidcLogin(t, router, provider, claims)
+		assert.Equal(t, userID, response["user_id"])
+	})
+
+	t.Run("should link to an existing account with the same verified email", func(t *testing.T) {
+		SignupAndLogin(router, "localuser", "password123")
+		now := time.Now()
+		testDB.Model(&models.User{}).Where("username = ?", "localuser").
+			Updates(map[string]interface{}{"email": "local@example.com", "email_verified_at": now})
+
+		response := oidcLogin(t, router, provider, jwt.MapClaims{"sub": "ext-2", "email": "local@example.com", "email_verified": true})
+
+		var user models.User
+		testDB.Where("username = ?", "localuser").First(&user)
+		assert.Equal(t, float64(user.ID), response["user_id"])
+
+		var identity models.UserIdentity
+		err := testDB.Where("subject = ?", "ext-2").First(&identity).Error
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, identity.UserID)
+	})
+
+	t.Run("should reject a reused or unknown state", func(t *testing.T) {
+		callback, cookies := startOIDCLogin(t, router, provider, jwt.MapClaims{"sub": "ext-3"})
+
+		w := PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", cookies)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", cookies)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject a callback from another browser", func(t *testing.T) {
+		// An attacker's own login, completed in the victim's browser
+		callback, _ := startOIDCLogin(t, router, provider, jwt.MapClaims{"sub": "ext-attacker"})
+		_, victimCookies := startOIDCLogin(t, router, provider, jwt.MapClaims{"sub": "ext-victim"})
+
+		w := PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", victimCookies)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var count int64
+		testDB.Model(&models.UserIdentity{}).Where("subject = ?", "ext-attacker").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("should sign up again after the linked account was deleted", func(t *testing.T) {
+		claims := jwt.MapClaims{"sub": "ext-5", "preferred_username": "leaving"}
+		response := oidcLogin(t, router, provider, claims)
+		userID := response["user_id"]
+
+		w := PerformRequest(router, "DELETE", "/users/me", nil, response["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var count int64
+		testDB.Model(&models.UserIdentity{}).Where("subject = ?", "ext-5").Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		response = oidcLogin(t, router, provider, claims)
+		assert.NotEqual(t, userID, response["user_id"])
+	})
+
+	t.Run("should treat an identity of a deleted user as unlinked", func(t *testing.T) {
+		claims := jwt.MapClaims{"sub": "ext-6", "preferred_username": "orphaned"}
+		response := oidcLogin(t, router, provider, claims)
+		userID := response["user_id"]
+
+		// Accounts deleted before identities were unlinked left them behind
+		testDB.Delete(&models.User{}, userID)
+
+		response = oidcLogin(t, router, provider, claims)
+		assert.NotEqual(t, userID, response["user_id"])
+	})
+
+	t.Run("should reject a state consumed by a concurrent callback", func(t *testing.T) {
+		callback, cookies := startOIDCLogin(t, router, provider, jwt.MapClaims{"sub": "ext-4"})
+
+		// Another callback with the same state deletes it right after this
+		// one has looked it up
+		consumed := false
+		testDB.Callback().Query().After("gorm:query").Register("test:consume_oidc_state", func(tx *gorm.DB) {
+			if consumed || tx.Statement.Table != "oidc_login_states" {
+				return
+			}
+			consumed = true
+			tx.Session(&gorm.Session{NewDB: true}).Exec("DELETE FROM oidc_login_states")
+		})
+		defer testDB.Callback().Query().Remove("test:consume_oidc_state")
+
+		w := PerformRequest(router, "GET", "/auth/oidc/callback?"+callback, nil, "", cookies)
+		assert.True(t, consumed)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var count int64
+		testDB.Model(&models.UserIdentity{}).Where("subject = ?", "ext-4").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -75,6 +75,8 @@ func TestCreateOrder(t *testing.T) {
 	router.ServeHTTP(w, req2)
 
 	t.Run("should create order from cart with valid token", func(t *testing.T) {
+		testDB.Model(&models.Item{}).Where("id = ?", 1).Update("sku", "ELEC-1")
+
 		// Make order request with token
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("POST", "/orders", nil)
@@ -99,6 +101,13 @@ func TestCreateOrder(t *testing.T) {
 		assert.Equal(t, user.ID, order.UserID)
 		assert.Greater(t, order.Total, 0.0)
 
+		// Lines without a variant carry the item's SKU
+		var lines []models.OrderItem
+		testDB.Where("order_id = ?", order.ID).Order("item_id").Find(&lines)
+		assert.Equal(t, 2, len(lines))
+		assert.Equal(t, "ELEC-1", lines[0].SKU)
+		assert.Equal(t, "", lines[1].SKU)
+
 		// Verify cart is cleared (no active cart items)
 		var cartItems []models.CartItem
 		err = testDB.Where("cart_id = ?", order.CartID).Find(&cartItems).Error
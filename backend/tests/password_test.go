@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"net/http"
+	"regexp"
+	"shopping-cart/models"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var resetTokenPattern = regexp.MustCompile(`Reset token: ([0-9a-f]+)`)
+
+func TestPasswordPolicy(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	t.Run("should reject a weak password on signup", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users", map[string]interface{}{"username": "weak", "password": "short"}, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "at least 8 characters")
+	})
+
+	t.Run("should apply configured rules", func(t *testing.T) {
+		t.Setenv("PASSWORD_REQUIRE_SYMBOL", "true")
+
+		w := PerformRequest(router, "POST", "/users", map[string]interface{}{"username": "nosymbol", "password": "password123"}, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "symbol")
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	oldToken := SignupAndLogin(router, "changetest", "password123")
+
+	t.Run("should reject a wrong old password", func(t *testing.T) {
+		body := map[string]interface{}{"old_password": "wrongpass1", "new_password": "newpassword456"}
+		w := PerformRequest(router, "POST", "/users/me/password", body, oldToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should change the password and revoke other sessions", func(t *testing.T) {
+		body := map[string]interface{}{"old_password": "password123", "new_password": "newpassword456"}
+		w := PerformRequest(router, "POST", "/users/me/password", body, oldToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		newToken := DecodeBody(w)["token"].(string)
+		assert.NotEmpty(t, newToken)
+
+		// The old token no longer works, the new one does
+		w = PerformRequest(router, "GET", "/users", nil, oldToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = PerformRequest(router, "GET", "/users", nil, newToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Login works with the new password only
+		w = PerformRequest(router, "POST", "/users/login", map[string]interface{}{"username": "changetest", "password": "newpassword456"}, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+	mailDir := UseTestMailer(t)
+
+	oldToken := SignupAndLogin(router, "resettest", "password123")
+	testDB.Model(&models.User{}).Where("username = ?", "resettest").Update("email", "reset@example.com")
+
+	t.Run("should not reveal unknown accounts", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/password/reset", map[string]interface{}{"username": "ghost"}, "", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, ReadMails(t, mailDir))
+	})
+
+	t.Run("should reset the password with the mailed token", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/password/reset", map[string]interface{}{"email": "reset@example.com"}, "", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		mails := ReadMails(t, mailDir)
+		assert.Equal(t, 1, len(mails))
+		match := resetTokenPattern.FindStringSubmatch(mails[0])
+		assert.Len(t, match, 2)
+
+		body := map[string]interface{}{"token": match[1], "new_password": "resetpassword789"}
+		w = PerformRequest(router, "POST", "/users/password/reset/confirm", body, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Old sessions are revoked and the token is single use
+		w = PerformRequest(router, "GET", "/users", nil, oldToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = PerformRequest(router, "POST", "/users/password/reset/confirm", body, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "POST", "/users/login", map[string]interface{}{"username": "resettest", "password": "resetpassword789"}, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestConcurrentPasswordReset(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+
+	// :memory: allows a single connection, which would serialize the requests
+	router := SetupFileTestRouter(t.TempDir(), 8)
+	defer closeFileTestDB()
+	mailDir := UseTestMailer(t)
+
+	SignupAndLogin(router, "parallelreset", "password123")
+	testDB.Model(&models.User{}).Where("username = ?", "parallelreset").Update("email", "parallel@example.com")
+
+	t.Run("should accept a reset token only once", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/password/reset", map[string]interface{}{"email": "parallel@example.com"}, "", nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		match := resetTokenPattern.FindStringSubmatch(ReadMails(t, mailDir)[0])
+		assert.Len(t, match, 2)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		codes := map[int]int{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				body := map[string]interface{}{"token": match[1], "new_password": "resetpassword789"}
+				w := PerformRequest(router, "POST", "/users/password/reset/confirm", body, "", nil)
+				mu.Lock()
+				codes[w.Code]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, codes[http.StatusOK])
+		assert.Equal(t, 7, codes[http.StatusBadRequest])
+	})
+}
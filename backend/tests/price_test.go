@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestPriceHistory(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "priceadmin", "password123")
+	token := SignupAndLogin(router, "pricecustomer", "password123")
+
+	var itemID uint
+
+	getPrices := func() map[string]interface{} {
+		w := PerformRequest(router, "GET", fmt.Sprintf("/items/%d/prices", itemID), nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return DecodeBody(w)
+	}
+
+	t.Run("should record initial and manual price changes", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Lamp", "price": 40.0}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		itemID = uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", itemID), map[string]interface{}{"price": 35.0}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		body := getPrices()
+		assert.Equal(t, 35.0, body["price"])
+		assert.Nil(t, body["was_price"])
+		history := body["history"].([]interface{})
+		assert.Len(t, history, 2)
+		latest := history[0].(map[string]interface{})
+		assert.Equal(t, "manual", latest["reason"])
+		assert.Equal(t, 40.0, latest["previous_price"])
+		assert.Nil(t, latest["effective_to"])
+		assert.NotNil(t, history[1].(map[string]interface{})["effective_to"])
+	})
+
+	t.Run("should only let admins change and schedule prices", func(t *testing.T) {
+		path := fmt.Sprintf("/items/%d/prices/schedule", itemID)
+		req := map[string]interface{}{"price": 30.0, "starts_at": time.Now().Add(time.Hour)}
+		assert.Equal(t, http.StatusForbidden, PerformRequest(router, "POST", path, req, token, nil).Code)
+
+		req["ends_at"] = time.Now()
+		assert.Equal(t, http.StatusBadRequest, PerformRequest(router, "POST", path, req, adminToken, nil).Code)
+
+		w := PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", itemID), map[string]interface{}{"price": 1.0}, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Len(t, getPrices()["history"], 2)
+	})
+
+	t.Run("should start and revert a sale", func(t *testing.T) {
+		start := time.Now().Add(time.Hour)
+		end := start.Add(24 * time.Hour)
+		path := fmt.Sprintf("/items/%d/prices/schedule", itemID)
+		w := PerformRequest(router, "POST", path, map[string]interface{}{"price": 25.0, "starts_at": start, "ends_at": end}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = PerformRequest(router, "POST", path, map[string]interface{}{"price": 20.0, "starts_at": start.Add(time.Hour), "ends_at": end.Add(time.Hour)}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		assert.NoError(t, utils.ApplyScheduledPrices(db, start.Add(time.Minute)))
+		body := getPrices()
+		assert.Equal(t, 25.0, body["price"])
+		assert.Equal(t, 35.0, body["was_price"])
+		assert.NotNil(t, body["sale_ends_at"])
+
+		assert.NoError(t, utils.ApplyScheduledPrices(db, end.Add(time.Minute)))
+		body = getPrices()
+		assert.Equal(t, 35.0, body["price"])
+		assert.Nil(t, body["was_price"])
+		assert.Equal(t, "sale_end", body["history"].([]interface{})[0].(map[string]interface{})["reason"])
+
+		var schedule models.ScheduledPrice
+		db.Where("item_id = ?", itemID).First(&schedule)
+		assert.Equal(t, models.PriceScheduleCompleted, schedule.Status)
+	})
+
+	t.Run("should end a sale early when cancelled", func(t *testing.T) {
+		path := fmt.Sprintf("/items/%d/prices/schedule", itemID)
+		w := PerformRequest(router, "POST", path, map[string]interface{}{
+			"price": 28.0, "starts_at": time.Now().Add(-time.Minute), "ends_at": time.Now().Add(time.Hour),
+		}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		schedule := DecodeBody(w)["scheduled_price"].(map[string]interface{})
+		assert.Equal(t, "active", schedule["status"])
+		assert.Equal(t, 28.0, getPrices()["price"])
+
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("%s/%d", path, uint(schedule["id"].(float64))), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 35.0, getPrices()["price"])
+	})
+
+	t.Run("should charge the current price at checkout", func(t *testing.T) {
+		path := fmt.Sprintf("/items/%d/prices/schedule", itemID)
+		w := PerformRequest(router, "POST", path, map[string]interface{}{
+			"price": 21.0, "starts_at": time.Now().Add(-time.Minute), "ends_at": time.Now().Add(time.Hour),
+		}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		scheduleID := uint(DecodeBody(w)["scheduled_price"].(map[string]interface{})["id"].(float64))
+
+		// Added during the sale, ordered after it ended
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": itemID, "quantity": 2}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("%s/%d", path, scheduleID), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		body := DecodeBody(w)
+		assert.Equal(t, 70.0, body["total"])
+
+		var line models.OrderItem
+		db.Where("order_id = ?", uint(body["order_id"].(float64))).First(&line)
+		assert.Equal(t, 35.0, line.Price)
+	})
+
+	t.Run("should apply a permanent scheduled change", func(t *testing.T) {
+		// Later than the simulated sale above, which ended a day from now
+		start := time.Now().Add(48 * time.Hour)
+		path := fmt.Sprintf("/items/%d/prices/schedule", itemID)
+		w := PerformRequest(router, "POST", path, map[string]interface{}{"price": 32.0, "starts_at": start}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		assert.NoError(t, utils.ApplyScheduledPrices(db, start.Add(time.Minute)))
+		body := getPrices()
+		assert.Equal(t, 32.0, body["price"])
+		assert.Nil(t, body["was_price"])
+		assert.Equal(t, "scheduled", body["history"].([]interface{})[0].(map[string]interface{})["reason"])
+	})
+}
+
+func TestScheduledSaleAppliedOnce(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "saleonceadmin", "password123")
+
+	w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Kettle", "price": 50.0}, adminToken, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	itemID := uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+
+	start := time.Now().Add(time.Hour)
+	end := start.Add(time.Hour)
+	schedule := models.ScheduledPrice{ItemID: itemID, Price: 40, StartsAt: start, EndsAt: &end, Status: models.PriceSchedulePending}
+	assert.NoError(t, db.Create(&schedule).Error)
+
+	// The job and the schedule handler may both read the same due schedule
+	// before either applies it. A query hook holds each run after reading
+	// schedules until the other run has read them too.
+	const runs = 2
+	applyConcurrently := func(now time.Time) {
+		var mu sync.Mutex
+		waiting := 0
+		release := make(chan struct{})
+		assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:schedule_barrier", func(tx *gorm.DB) {
+			if tx.Statement.Table != "scheduled_prices" {
+				return
+			}
+			mu.Lock()
+			waiting++
+			wait := release
+			if waiting == runs {
+				waiting = 0
+				close(release)
+				release = make(chan struct{})
+			}
+			mu.Unlock()
+			select {
+			case <-wait:
+			case <-time.After(2 * time.Second):
+			}
+		}))
+		defer db.Callback().Query().Remove("test:schedule_barrier")
+
+		var wg sync.WaitGroup
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, utils.ApplyScheduledPrices(db, now))
+			}()
+		}
+		wg.Wait()
+	}
+	countReason := func(reason string) int64 {
+		var count int64
+		db.Model(&models.PriceHistory{}).Where("item_id = ? AND reason = ?", itemID, reason).Count(&count)
+		return count
+	}
+
+	applyConcurrently(start.Add(time.Minute))
+	db.First(&schedule, schedule.ID)
+	assert.Equal(t, models.PriceScheduleActive, schedule.Status)
+	if assert.NotNil(t, schedule.OriginalPrice) {
+		assert.Equal(t, 50.0, *schedule.OriginalPrice)
+	}
+	assert.Equal(t, int64(1), countReason(models.PriceReasonSaleStart))
+
+	applyConcurrently(end.Add(time.Minute))
+	var item models.Item
+	db.First(&item, itemID)
+	assert.Equal(t, 50.0, item.Price)
+	assert.Equal(t, int64(1), countReason(models.PriceReasonSaleEnd))
+}
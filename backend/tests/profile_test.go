@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserProfile(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "profiletest", "password123")
+
+	t.Run("should return the profile without credentials", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/users/me", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		user := DecodeBody(w)["user"].(map[string]interface{})
+		assert.Equal(t, "profiletest", user["username"])
+		assert.NotContains(t, user, "password")
+		assert.NotContains(t, user, "token")
+	})
+
+	t.Run("should update email, display name and preferences", func(t *testing.T) {
+		body := map[string]interface{}{
+			"email":        "profile@example.com",
+			"display_name": "Profile Tester",
+			"preferences":  map[string]interface{}{"currency": "EUR", "newsletter": true},
+		}
+		w := PerformRequest(router, "PATCH", "/users/me", body, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Preferences are merged and null removes a key
+		body = map[string]interface{}{"preferences": map[string]interface{}{"newsletter": nil, "language": "de"}}
+		w = PerformRequest(router, "PATCH", "/users/me", body, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		user := DecodeBody(w)["user"].(map[string]interface{})
+		assert.Equal(t, "profile@example.com", user["email"])
+		assert.Equal(t, "Profile Tester", user["display_name"])
+		assert.Equal(t, map[string]interface{}{"currency": "EUR", "language": "de"}, user["preferences"])
+	})
+
+	t.Run("should reject an invalid email", func(t *testing.T) {
+		w := PerformRequest(router, "PATCH", "/users/me", map[string]interface{}{"email": "not-an-email"}, token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should not leak credentials when listing users", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/users", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "password")
+		assert.NotContains(t, w.Body.String(), token)
+	})
+
+	t.Run("should not expose private fields when listing users", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/users", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "profile@example.com")
+
+		users := DecodeBody(w)["users"].([]interface{})
+		assert.NotEmpty(t, users)
+		for _, user := range users {
+			fields := user.(map[string]interface{})
+			assert.Contains(t, fields, "username")
+			assert.NotContains(t, fields, "email")
+			assert.NotContains(t, fields, "email_verified")
+			assert.NotContains(t, fields, "preferences")
+			assert.NotContains(t, fields, "two_factor_enabled")
+		}
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "deletetest", "password123")
+	PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+	w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	t.Run("should anonymize the account and keep orders", func(t *testing.T) {
+		w := PerformRequest(router, "DELETE", "/users/me", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var user models.User
+		err := testDB.Unscoped().Where("username = ?", "deletetest").First(&user).Error
+		assert.Error(t, err)
+
+		var orders []models.Order
+		testDB.Find(&orders)
+		assert.Equal(t, 1, len(orders))
+
+		err = testDB.Unscoped().First(&user, orders[0].UserID).Error
+		assert.NoError(t, err)
+		assert.Contains(t, user.Username, "deleted-user-")
+		assert.Empty(t, user.Email)
+	})
+
+	t.Run("should revoke the session and free the username", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/users/me", nil, token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = PerformRequest(router, "POST", "/users/login", map[string]interface{}{"username": "deletetest", "password": "password123"}, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		newToken := SignupAndLogin(router, "deletetest", "password123")
+		assert.NotEmpty(t, newToken)
+	})
+}
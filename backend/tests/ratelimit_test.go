@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"net/http"
+	"shopping-cart/models"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiting(t *testing.T) {
+	t.Setenv("RATE_LIMIT_AUTH_RPS", "0.01")
+	t.Setenv("RATE_LIMIT_AUTH_BURST", "3")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	t.Run("should throttle login attempts per IP", func(t *testing.T) {
+		loginData := map[string]interface{}{
+			"username": "nobody",
+			"password": "password123",
+		}
+
+		for i := 0; i < 3; i++ {
+			w := PerformRequest(router, "POST", "/users/login", loginData, "", nil)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+
+		w := PerformRequest(router, "POST", "/users/login", loginData, "", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_LOCKOUT_BASE_SECONDS", "60")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	SignupAndLogin(router, "lockouttest", "password123")
+	badLogin := map[string]interface{}{
+		"username": "lockouttest",
+		"password": "wrong-password",
+	}
+
+	t.Run("should lock the account after repeated failed logins", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			w := PerformRequest(router, "POST", "/users/login", badLogin, "", nil)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Empty(t, w.Header().Get("Retry-After"))
+		}
+
+		w := PerformRequest(router, "POST", "/users/login", badLogin, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("should reject the correct password while locked", func(t *testing.T) {
+		goodLogin := map[string]interface{}{
+			"username": "lockouttest",
+			"password": "password123",
+		}
+
+		w := PerformRequest(router, "POST", "/users/login", goodLogin, "", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("should double the lock on the next failure", func(t *testing.T) {
+		// Expire the current lock so the password is checked again
+		testDB.Model(&models.User{}).Where("username = ?", "lockouttest").Update("locked_until", nil)
+
+		w := PerformRequest(router, "POST", "/users/login", badLogin, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	})
+}
+
+func TestConcurrentFailedLogins(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "3")
+	t.Setenv("LOGIN_LOCKOUT_BASE_SECONDS", "60")
+
+	// :memory: allows a single connection, which would serialize the requests
+	router := SetupFileTestRouter(t.TempDir(), 8)
+	defer closeFileTestDB()
+
+	SignupAndLogin(router, "parallellockout", "password123")
+	badLogin := map[string]interface{}{
+		"username": "parallellockout",
+		"password": "wrong-password",
+	}
+
+	t.Run("should count every parallel failed login", func(t *testing.T) {
+		const attempts = 10
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		failed := 0
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := PerformRequest(router, "POST", "/users/login", badLogin, "", nil)
+				if w.Code == http.StatusUnauthorized {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		var user models.User
+		testDB.Where("username = ?", "parallellockout").First(&user)
+		assert.Equal(t, failed, user.FailedLoginAttempts)
+		assert.GreaterOrEqual(t, user.FailedLoginAttempts, 3)
+		assert.NotNil(t, user.LockedUntil)
+	})
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendations(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "recadmin", "password123")
+	token := SignupAndLogin(router, "recbuyer", "password123")
+
+	createItem := func(name string) uint {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": name, "price": 10.0, "category": "Audio"}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		return uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+	}
+	headphonesID := createItem("Headphones")
+	speakerID := createItem("Speaker")
+
+	placeOrder := func(itemIDs ...uint) uint {
+		for _, id := range itemIDs {
+			w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": id, "quantity": 1}, token, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		return uint(DecodeBody(w)["order_id"].(float64))
+	}
+
+	relatedIDs := func(itemID uint) []uint {
+		w := PerformRequest(router, "GET", fmt.Sprintf("/items/%d/related", itemID), nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var ids []uint
+		for _, entry := range DecodeBody(w)["items"].([]interface{}) {
+			ids = append(ids, uint(entry.(map[string]interface{})["item"].(map[string]interface{})["id"].(float64)))
+		}
+		return ids
+	}
+
+	placeOrder(1, headphonesID)
+	placeOrder(1, headphonesID)
+	placeOrder(1, speakerID)
+	cancelled := placeOrder(1, 2)
+	w := PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", cancelled), map[string]interface{}{"status": "cancelled"}, adminToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("should be empty until the job has run", func(t *testing.T) {
+		assert.Empty(t, relatedIDs(1))
+	})
+
+	assert.NoError(t, utils.RebuildRecommendations(db, time.Now()))
+
+	t.Run("should rank by co-purchases and skip cancelled orders", func(t *testing.T) {
+		assert.Equal(t, []uint{headphonesID, speakerID}, relatedIDs(1))
+
+		w := PerformRequest(router, "GET", fmt.Sprintf("/items/%d/related", 1), nil, "", nil)
+		first := DecodeBody(w)["items"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, float64(2), first["co_purchases"])
+	})
+
+	t.Run("should include items of the same category", func(t *testing.T) {
+		// Speaker was never bought with headphones but shares their category
+		assert.Equal(t, []uint{1, speakerID}, relatedIDs(headphonesID))
+
+		w := PerformRequest(router, "GET", fmt.Sprintf("/items/%d/related?limit=1", headphonesID), nil, "", nil)
+		assert.Len(t, DecodeBody(w)["items"], 1)
+	})
+
+	t.Run("should leave out items that cannot be bought", func(t *testing.T) {
+		w := PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", speakerID), map[string]interface{}{"status": "archived"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []uint{headphonesID}, relatedIDs(1))
+		PerformRequest(router, "PUT", fmt.Sprintf("/items/%d", speakerID), map[string]interface{}{"status": "active"}, adminToken, nil)
+	})
+
+	t.Run("should recommend for the cart contents", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/carts/recommendations", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, DecodeBody(w)["items"])
+
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": headphonesID, "quantity": 1}, token, nil)
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+
+		w = PerformRequest(router, "GET", "/carts/recommendations", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		items := DecodeBody(w)["items"].([]interface{})
+		assert.Len(t, items, 1)
+		entry := items[0].(map[string]interface{})
+		assert.Equal(t, float64(speakerID), entry["item"].(map[string]interface{})["id"])
+		// Bought once with item 1 and in the same category as the headphones
+		assert.Equal(t, 1.5, entry["score"])
+	})
+}
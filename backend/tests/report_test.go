@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderReports(t *testing.T) {
+	t.Setenv("INVOICE_TAX_RATE", "0.20")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "reportadmin", "password123")
+	firstToken := SignupAndLogin(router, "reportbuyer1", "password123")
+	secondToken := SignupAndLogin(router, "reportbuyer2", "password123")
+
+	var second models.User
+	db.Where("username = ?", "reportbuyer2").First(&second)
+
+	placeOrder := func(token string, itemID uint, quantity int, placedAt time.Time, status string) uint {
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": itemID, "quantity": quantity}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		id := uint(DecodeBody(w)["order_id"].(float64))
+
+		db.Model(&models.Order{}).Where("id = ?", id).UpdateColumn("created_at", placedAt)
+		if status != models.OrderPending {
+			w = PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", id), map[string]interface{}{"status": status}, adminToken, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		return id
+	}
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	// 21.98 + 20.99 in the week of Monday 5 January, 10.99 in February
+	placeOrder(firstToken, 1, 2, day(time.January, 5), models.OrderPaid)
+	placeOrder(firstToken, 2, 1, day(time.January, 7), models.OrderShipped)
+	placeOrder(secondToken, 1, 1, day(time.February, 10), models.OrderDelivered)
+	pending := placeOrder(secondToken, 2, 1, day(time.February, 11), models.OrderPending)
+
+	t.Run("should filter the admin order list", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/orders/all?status=pending", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := DecodeBody(w)
+		assert.Equal(t, float64(1), response["total"])
+		assert.Equal(t, float64(pending), response["orders"].([]interface{})[0].(map[string]interface{})["id"])
+
+		w = PerformRequest(router, "GET", "/orders/all?from=2026-01-06&to=2026-02-10", nil, adminToken, nil)
+		assert.Equal(t, float64(2), DecodeBody(w)["total"])
+
+		w = PerformRequest(router, "GET", fmt.Sprintf("/orders/all?user_id=%d&page_size=1", second.ID), nil, adminToken, nil)
+		response = DecodeBody(w)
+		assert.Equal(t, float64(2), response["total"])
+		assert.Len(t, response["orders"], 1)
+		assert.Equal(t, float64(1), response["page"])
+	})
+
+	t.Run("should reject bad filters", func(t *testing.T) {
+		for _, query := range []string{"from=yesterday", "status=lost", "user_id=abc", "from=2026-02-01&to=2026-01-01"} {
+			w := PerformRequest(router, "GET", "/admin/reports/revenue?"+query, nil, adminToken, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+		w := PerformRequest(router, "GET", "/admin/reports/revenue?period=year", nil, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should cap the number of periods", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/revenue?period=day&from=2020-01-01&to=2026-01-01", nil, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Without from the range starts at the first order
+		w = PerformRequest(router, "GET", "/admin/reports/revenue?period=day&to=2030-01-01", nil, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "GET", "/admin/reports/revenue?period=month&from=2020-01-01&to=2026-01-01", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should total paid revenue by month", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/revenue?period=month&from=2026-01-01&to=2026-03-31", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := DecodeBody(w)
+
+		summary := response["summary"].(map[string]interface{})
+		assert.Equal(t, float64(3), summary["orders"])
+		assert.Equal(t, 53.96, summary["revenue"])
+		assert.Equal(t, 17.99, summary["average_order_value"])
+		// 3.66 + 3.50 + 1.83 of VAT from the invoices
+		assert.Equal(t, 8.99, summary["tax"])
+
+		points := response["points"].([]interface{})
+		if assert.Len(t, points, 3) {
+			january := points[0].(map[string]interface{})
+			assert.Equal(t, "2026-01-01T00:00:00Z", january["period_start"])
+			assert.Equal(t, float64(2), january["orders"])
+			assert.Equal(t, 42.97, january["revenue"])
+			assert.InDelta(t, 21.49, january["average_order_value"], 0.011)
+			assert.Equal(t, float64(1), points[1].(map[string]interface{})["orders"])
+			assert.Equal(t, float64(0), points[2].(map[string]interface{})["orders"])
+		}
+	})
+
+	t.Run("should bucket weeks from Monday", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/revenue?period=week&from=2026-01-05&to=2026-01-18", nil, adminToken, nil)
+		points := DecodeBody(w)["points"].([]interface{})
+		if assert.Len(t, points, 2) {
+			assert.Equal(t, "2026-01-05T00:00:00Z", points[0].(map[string]interface{})["period_start"])
+			assert.Equal(t, float64(2), points[0].(map[string]interface{})["orders"])
+			assert.Equal(t, "2026-01-12T00:00:00Z", points[1].(map[string]interface{})["period_start"])
+		}
+
+		w = PerformRequest(router, "GET", "/admin/reports/revenue?status=pending", nil, adminToken, nil)
+		points = DecodeBody(w)["points"].([]interface{})
+		if assert.Len(t, points, 1) {
+			assert.Equal(t, "2026-02-11T00:00:00Z", points[0].(map[string]interface{})["period_start"])
+		}
+	})
+
+	t.Run("should rank top items and categories", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/top-items", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		items := DecodeBody(w)["items"].([]interface{})
+		if assert.Len(t, items, 2) {
+			top := items[0].(map[string]interface{})
+			assert.Equal(t, float64(1), top["item_id"])
+			assert.Equal(t, "Test Item 1", top["name"])
+			assert.Equal(t, float64(3), top["quantity"])
+			assert.Equal(t, float64(2), top["orders"])
+			assert.Equal(t, 32.97, top["revenue"])
+		}
+
+		w = PerformRequest(router, "GET", "/admin/reports/top-items?limit=1&from=2026-01-06", nil, adminToken, nil)
+		items = DecodeBody(w)["items"].([]interface{})
+		if assert.Len(t, items, 1) {
+			assert.Equal(t, float64(2), items[0].(map[string]interface{})["item_id"])
+		}
+
+		w = PerformRequest(router, "GET", "/admin/reports/top-categories", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		categories := DecodeBody(w)["categories"].([]interface{})
+		if assert.Len(t, categories, 2) {
+			assert.Equal(t, "Electronics", categories[0].(map[string]interface{})["name"])
+			assert.Equal(t, 32.97, categories[0].(map[string]interface{})["revenue"])
+			assert.Equal(t, "Books", categories[1].(map[string]interface{})["name"])
+		}
+	})
+
+	t.Run("should export order lines as CSV", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/orders/export?status=paid,shipped", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="orders.csv"`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, records, 3) {
+			assert.Equal(t, "order_id", records[0][0])
+			assert.Equal(t, []string{"paid", "reportbuyer1", "INV-000001", "21.98", "Test Item 1", "2", "10.99", "21.98"},
+				[]string{records[1][2], records[1][4], records[1][6], records[1][7], records[1][10], records[1][11], records[1][12], records[1][13]})
+			assert.Equal(t, "2026-01-05T12:00:00Z", records[1][1])
+		}
+	})
+
+	t.Run("should escape formulas in CSV exports", func(t *testing.T) {
+		db.Model(&models.OrderItem{}).Where("order_id = ?", pending).Update("name", `=HYPERLINK("http://example.com")`)
+		defer db.Model(&models.OrderItem{}).Where("order_id = ?", pending).Update("name", "Test Item 2")
+
+		w := PerformRequest(router, "GET", "/admin/reports/orders/export?status=pending", nil, adminToken, nil)
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, records, 2) {
+			assert.Equal(t, `'=HYPERLINK("http://example.com")`, records[1][10])
+			assert.Equal(t, "pending", records[1][2])
+		}
+	})
+
+	t.Run("should export order lines as JSON Lines", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/orders/export?format=jsonl", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Len(t, lines, 4)
+
+		var last map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[3]), &last))
+		assert.Equal(t, float64(pending), last["order_id"])
+		assert.Equal(t, "", last["invoice_number"])
+
+		w = PerformRequest(router, "GET", "/admin/reports/orders/export?format=xlsx", nil, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should be admin only", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/admin/reports/revenue", nil, firstToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
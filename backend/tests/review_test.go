@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestReviews(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "reviewadmin", "password123")
+	buyerToken := SignupAndLogin(router, "reviewbuyer", "password123")
+	otherToken := SignupAndLogin(router, "reviewother", "password123")
+
+	var orderID uint
+	var reviewID uint
+
+	t.Run("should require a delivered order", func(t *testing.T) {
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, buyerToken, nil)
+		w := PerformRequest(router, "POST", "/orders", nil, buyerToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		orderID = uint(DecodeBody(w)["order_id"].(float64))
+
+		review := map[string]interface{}{"rating": 4, "title": "Good"}
+		w = PerformRequest(router, "POST", "/items/1/reviews", review, buyerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", orderID), map[string]interface{}{"status": "delivered"}, buyerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/admin/orders/%d/status", orderID), map[string]interface{}{"status": "delivered"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "POST", "/items/1/reviews", map[string]interface{}{"rating": 6}, buyerToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "POST", "/items/1/reviews", review, buyerToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		created := DecodeBody(w)["review"].(map[string]interface{})
+		reviewID = uint(created["id"].(float64))
+		assert.Equal(t, "reviewbuyer", created["username"])
+
+		w = PerformRequest(router, "POST", "/items/1/reviews", review, buyerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// Bought item 1 only
+		w = PerformRequest(router, "POST", "/items/2/reviews", review, buyerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = PerformRequest(router, "POST", "/items/1/reviews", review, otherToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should compute the item rating", func(t *testing.T) {
+		// A second delivered buyer, inserted directly
+		var other models.User
+		db.Where("username = ?", "reviewother").First(&other)
+		order := models.Order{UserID: other.ID, Total: 10.99, Status: models.OrderDelivered, Items: []models.OrderItem{{ItemID: 1, Quantity: 1, Price: 10.99}}}
+		db.Create(&order)
+
+		w := PerformRequest(router, "POST", "/items/1/reviews", map[string]interface{}{"rating": 1, "body": "Broke"}, otherToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, 2.5, item.Rating)
+		assert.Equal(t, 2, item.Reviews)
+
+		// Callers cannot set ratings directly
+		w = PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Fake", "price": 1.0, "rating": 5, "reviews": 1000}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		created := DecodeBody(w)["item"].(map[string]interface{})
+		assert.Equal(t, float64(0), created["rating"])
+		assert.Equal(t, float64(0), created["reviews"])
+	})
+
+	t.Run("should sort and paginate", func(t *testing.T) {
+		w := PerformRequest(router, "GET", "/items/1/reviews?sort=lowest&page_size=1", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := DecodeBody(w)
+		reviews := response["reviews"].([]interface{})
+		assert.Equal(t, 1, len(reviews))
+		assert.Equal(t, float64(1), reviews[0].(map[string]interface{})["rating"])
+		assert.Equal(t, float64(2), response["total"])
+
+		w = PerformRequest(router, "GET", "/items/1/reviews?sort=lowest&page_size=1&page=2", nil, "", nil)
+		reviews = DecodeBody(w)["reviews"].([]interface{})
+		assert.Equal(t, float64(4), reviews[0].(map[string]interface{})["rating"])
+
+		w = PerformRequest(router, "GET", "/items/1/reviews?sort=random", nil, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should let admins moderate", func(t *testing.T) {
+		w := PerformRequest(router, "PUT", fmt.Sprintf("/admin/reviews/%d", reviewID), map[string]interface{}{"status": "rejected"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, 1.0, item.Rating)
+		assert.Equal(t, 1, item.Reviews)
+
+		w = PerformRequest(router, "GET", "/admin/reviews?status=rejected", nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, len(DecodeBody(w)["reviews"].([]interface{})))
+
+		w = PerformRequest(router, "GET", "/items/1/reviews", nil, "", nil)
+		assert.Equal(t, 1, len(DecodeBody(w)["reviews"].([]interface{})))
+
+		w = PerformRequest(router, "DELETE", fmt.Sprintf("/admin/reviews/%d", reviewID), nil, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should hold reviews for approval when configured", func(t *testing.T) {
+		t.Setenv("REVIEWS_REQUIRE_APPROVAL", "true")
+
+		// A review removed by a moderator still counts as the buyer's one
+		w := PerformRequest(router, "POST", "/items/1/reviews", map[string]interface{}{"rating": 5}, buyerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		thirdToken := SignupAndLogin(router, "reviewthird", "password123")
+		var third models.User
+		db.Where("username = ?", "reviewthird").First(&third)
+		db.Create(&models.Order{UserID: third.ID, Total: 10.99, Status: models.OrderDelivered, Items: []models.OrderItem{{ItemID: 1, Quantity: 1, Price: 10.99}}})
+
+		w = PerformRequest(router, "POST", "/items/1/reviews", map[string]interface{}{"rating": 5}, thirdToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "pending", DecodeBody(w)["review"].(map[string]interface{})["status"])
+
+		var item models.Item
+		db.First(&item, 1)
+		assert.Equal(t, 1, item.Reviews)
+	})
+
+	t.Run("should reset ratings that no review backs", func(t *testing.T) {
+		// Item 2 was seeded with a rating but has no reviews
+		var item models.Item
+		db.First(&item, 2)
+		assert.NotZero(t, item.Reviews)
+
+		assert.NoError(t, utils.RecomputeItemRatings(db))
+		db.First(&item, 2)
+		assert.Equal(t, 0.0, item.Rating)
+		assert.Equal(t, 0, item.Reviews)
+
+		var reviewed models.Item
+		db.First(&reviewed, 1)
+		assert.Equal(t, 1, reviewed.Reviews)
+	})
+
+	t.Run("should reject a review posted by a parallel request", func(t *testing.T) {
+		fourthToken := SignupAndLogin(router, "reviewfourth", "password123")
+		var fourth models.User
+		db.Where("username = ?", "reviewfourth").First(&fourth)
+		db.Create(&models.Order{UserID: fourth.ID, Total: 10.99, Status: models.OrderDelivered, Items: []models.OrderItem{{ItemID: 1, Quantity: 1, Price: 10.99}}})
+
+		// The other request inserts its review after this one checked
+		posted := false
+		db.Callback().Query().After("gorm:query").Register("test:parallel_review", func(tx *gorm.DB) {
+			if posted || tx.Statement.Table != "reviews" {
+				return
+			}
+			posted = true
+			tx.Session(&gorm.Session{NewDB: true}).Create(&models.Review{ItemID: 1, UserID: fourth.ID, Rating: 3, Status: models.ReviewApproved})
+		})
+		defer db.Callback().Query().Remove("test:parallel_review")
+
+		w := PerformRequest(router, "POST", "/items/1/reviews", map[string]interface{}{"rating": 5}, fourthToken, nil)
+		assert.True(t, posted)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var count int64
+		db.Model(&models.Review{}).Where("item_id = ? AND user_id = ?", 1, fourth.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/search"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSearchIndex(t *testing.T) {
+	idx := search.NewIndex(search.Field{Name: "name", Weight: 3}, search.Field{Name: "description", Weight: 1})
+	idx.Add(search.Document{ID: 1, Fields: map[string]string{"name": "Running Shoes", "description": "Light shoes for daily runs"}, Suggest: true})
+	idx.Add(search.Document{ID: 2, Fields: map[string]string{"name": "Shoe Rack", "description": "Holds running gear"}, Suggest: true})
+	idx.Add(search.Document{ID: 3, Fields: map[string]string{"name": "Secret Prototype", "description": "Not public"}})
+
+	ids := func(results []search.Result) []uint {
+		var out []uint
+		for _, result := range results {
+			out = append(out, result.ID)
+		}
+		return out
+	}
+
+	t.Run("should stem words", func(t *testing.T) {
+		assert.Equal(t, search.Stem("shoes"), search.Stem("shoe"))
+		assert.Equal(t, search.Stem("running"), search.Stem("run"))
+		assert.Equal(t, search.Stem("batteries"), search.Stem("battery"))
+		assert.Equal(t, []string{"wireless", "headphones"}, search.Words("The Wireless-Headphones!"))
+	})
+
+	t.Run("should rank name matches first", func(t *testing.T) {
+		assert.Equal(t, []uint{1, 2}, ids(idx.Search("run")))
+		assert.Equal(t, []uint{2}, ids(idx.Search("rack")))
+		assert.Empty(t, idx.Search("the"))
+	})
+
+	t.Run("should require every known word", func(t *testing.T) {
+		assert.Equal(t, []uint{2}, ids(idx.Search("shoe rack")))
+		assert.Equal(t, []uint{2}, ids(idx.Search("shoe rack xyzzy")))
+	})
+
+	t.Run("should tolerate typos and prefixes", func(t *testing.T) {
+		assert.Equal(t, []uint{2}, ids(idx.Search("shoe rakc")))
+		assert.Equal(t, []uint{3}, ids(idx.Search("prototpye")))
+		assert.Equal(t, []uint{3}, ids(idx.Search("protot")))
+		assert.Empty(t, idx.Search("protot "))
+	})
+
+	t.Run("should limit searches to visible documents", func(t *testing.T) {
+		now := time.Now()
+		later := now.Add(time.Hour)
+		scheduled := search.NewIndex(search.Field{Name: "name", Weight: 1})
+		scheduled.Add(search.Document{ID: 1, Fields: map[string]string{"name": "Lamp"}})
+		scheduled.Add(search.Document{ID: 2, Fields: map[string]string{"name": "Lamp"}, Visibility: search.Visibility{Hidden: true}})
+		scheduled.Add(search.Document{ID: 3, Fields: map[string]string{"name": "Lamp"}, Visibility: search.Visibility{From: &later}})
+		scheduled.Add(search.Document{ID: 4, Fields: map[string]string{"name": "Lamp"}, Visibility: search.Visibility{Until: &later}})
+
+		assert.Equal(t, []uint{1, 2, 3, 4}, ids(scheduled.Search("lamp")))
+		assert.Equal(t, []uint{1, 4}, ids(scheduled.SearchVisible("lamp", now)))
+		assert.Equal(t, []uint{1, 3}, ids(scheduled.SearchVisible("lamp", later)))
+	})
+
+	t.Run("should update and remove documents", func(t *testing.T) {
+		idx.Add(search.Document{ID: 2, Fields: map[string]string{"name": "Boot Rack"}})
+		assert.Equal(t, []uint{1}, ids(idx.Search("shoe")))
+		idx.Remove(1)
+		assert.Empty(t, idx.Search("shoe"))
+		assert.Equal(t, 2, idx.Len())
+	})
+
+	t.Run("should suggest only from suggestable documents", func(t *testing.T) {
+		idx.Add(search.Document{ID: 1, Fields: map[string]string{"name": "Running Shoes", "description": "Light shoes for daily runs"}, Suggest: true})
+		assert.Equal(t, []string{"running", "runs"}, idx.Suggest("run", 10))
+		assert.Equal(t, []string{"daily running"}, idx.Suggest("daily runn", 10))
+		assert.Equal(t, []string{"shoes"}, idx.Suggest("shose", 10))
+		assert.Empty(t, idx.Suggest("secr", 10))
+	})
+}
+
+func TestSearchEndpoints(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "searchadmin", "password123")
+
+	searchIDs := func(query, token string) []uint {
+		w := PerformRequest(router, "GET", "/search?q="+query, nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var ids []uint
+		for _, entry := range DecodeBody(w)["items"].([]interface{}) {
+			ids = append(ids, uint(entry.(map[string]interface{})["item"].(map[string]interface{})["id"].(float64)))
+		}
+		return ids
+	}
+
+	var headphonesID uint
+
+	t.Run("should find seeded and new items", func(t *testing.T) {
+		assert.Equal(t, []uint{2}, searchIDs("books", ""))
+
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{
+			"name": "Wireless Headphones", "description": "Noise cancelling", "price": 99.0, "category": "Audio",
+		}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		headphonesID = uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+
+		assert.Equal(t, []uint{headphonesID}, searchIDs("wireless+headphnes", ""))
+		assert.Equal(t, []uint{headphonesID}, searchIDs("audio", ""))
+
+		w = PerformRequest(router, "GET", "/search", nil, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should follow updates and deletes", func(t *testing.T) {
+		path := fmt.Sprintf("/items/%d", headphonesID)
+		PerformRequest(router, "PUT", path, map[string]interface{}{"name": "Studio Monitors"}, adminToken, nil)
+		assert.Empty(t, searchIDs("headphones", ""))
+		assert.Equal(t, []uint{headphonesID}, searchIDs("monitor", ""))
+
+		PerformRequest(router, "PUT", path, map[string]interface{}{"status": "draft"}, adminToken, nil)
+		assert.Empty(t, searchIDs("monitor", ""))
+		assert.Equal(t, []uint{headphonesID}, searchIDs("monitor", adminToken))
+
+		w := PerformRequest(router, "GET", "/search/suggest?q=stu", nil, "", nil)
+		assert.Empty(t, DecodeBody(w)["suggestions"])
+
+		PerformRequest(router, "PUT", path, map[string]interface{}{"status": "active"}, adminToken, nil)
+		w = PerformRequest(router, "GET", "/search/suggest?q=stu", nil, "", nil)
+		assert.Equal(t, []interface{}{"studio"}, DecodeBody(w)["suggestions"])
+
+		PerformRequest(router, "DELETE", path, nil, adminToken, nil)
+		assert.Empty(t, searchIDs("monitor", adminToken))
+	})
+
+	t.Run("should load only the requested page", func(t *testing.T) {
+		for _, item := range []map[string]interface{}{
+			{"name": "Gadget One", "price": 10.0},
+			{"name": "Gadget Two", "price": 10.0},
+			{"name": "Gadget Three", "price": 10.0},
+			{"name": "Gadget Draft", "price": 10.0, "status": "draft"},
+		} {
+			w := PerformRequest(router, "POST", "/items", item, adminToken, nil)
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+
+		loaded := int64(0)
+		db.Callback().Query().After("gorm:query").Register("test:count_search_rows", func(tx *gorm.DB) {
+			if tx.Statement.Table == "items" {
+				loaded = max(loaded, tx.Statement.RowsAffected)
+			}
+		})
+		defer db.Callback().Query().Remove("test:count_search_rows")
+
+		w := PerformRequest(router, "GET", "/search?q=gadget&page=2&page_size=2", nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		response := DecodeBody(w)
+		assert.Equal(t, float64(3), response["total"])
+		assert.Len(t, response["items"], 1)
+		assert.Equal(t, int64(1), loaded)
+
+		w = PerformRequest(router, "GET", "/search?q=gadget&page_size=2", nil, adminToken, nil)
+		response = DecodeBody(w)
+		assert.Equal(t, float64(4), response["total"])
+		assert.Len(t, response["items"], 2)
+	})
+
+	t.Run("should reindex items of a renamed category", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items", map[string]interface{}{"name": "Tuner", "price": 10.0, "category": "Radio"}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		item := DecodeBody(w)["item"].(map[string]interface{})
+		categoryID := uint(item["category_id"].(float64))
+
+		w = PerformRequest(router, "PUT", fmt.Sprintf("/categories/%d", categoryID), map[string]interface{}{"name": "Hifi"}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []uint{uint(item["id"].(float64))}, searchIDs("hifi", ""))
+	})
+}
@@ -1,6 +1,12 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"shopping-cart/models"
 	"shopping-cart/routes"
 	"shopping-cart/utils"
@@ -17,13 +23,32 @@ var testDB *gorm.DB
 
 // SetupTestDB initializes an in-memory SQLite database for testing
 func SetupTestDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+	// Every new connection to :memory: is a separate database, so keep one
+	return openTestDB(":memory:", 1)
+}
+
+// SetupFileTestDB initializes a SQLite database file in dir. Unlike
+// :memory: it can be shared by several connections, so requests really
+// run concurrently. Writers wait for each other instead of failing.
+func SetupFileTestDB(dir string, maxOpenConns int) *gorm.DB {
+	dsn := filepath.Join(dir, "test.db") + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	return openTestDB(dsn, maxOpenConns)
+}
+
+func openTestDB(dsn string, maxOpenConns int) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
 	})
 	if err != nil {
 		panic("Failed to connect to test database: " + err.Error())
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic("Failed to access test database: " + err.Error())
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+
 	// Auto migrate the schema
 	err = db.AutoMigrate(
 		&models.User{},
@@ -31,20 +56,59 @@ func SetupTestDB() *gorm.DB {
 		&models.Cart{},
 		&models.CartItem{},
 		&models.Order{},
+		&models.IdempotencyKey{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIKey{},
+		&models.Category{},
+		&models.OptionType{},
+		&models.OptionValue{},
+		&models.Variant{},
+		&models.OrderItem{},
+		&models.Review{},
+		&models.ItemImage{},
+		&models.PriceHistory{},
+		&models.ScheduledPrice{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.ItemRecommendation{},
+		&models.ItemView{},
+		&models.GuestToken{},
+		&models.ItemViewCount{},
+		&models.Invoice{},
+		&models.CartSession{},
+		&models.HourlyMetric{},
+		&models.DailyMetric{},
 	)
 	if err != nil {
 		panic("Failed to migrate test database: " + err.Error())
 	}
+	if err := utils.EnsureIndexes(db); err != nil {
+		panic("Failed to create test indexes: " + err.Error())
+	}
 
 	return db
 }
 
 // SetupTestRouter creates a test router with the test database
 func SetupTestRouter() *gin.Engine {
+	return setupRouterWithDB(SetupTestDB())
+}
+
+// SetupFileTestRouter creates a test router backed by SetupFileTestDB
+func SetupFileTestRouter(dir string, maxOpenConns int) *gin.Engine {
+	return setupRouterWithDB(SetupFileTestDB(dir, maxOpenConns))
+}
+
+func setupRouterWithDB(db *gorm.DB) *gin.Engine {
 	// Setup test database
-	testDB = SetupTestDB()
+	testDB = db
 	utils.DB = testDB
 	SeedTestData(testDB)
+	utils.BuildSearchIndex(testDB)
 
 	// Setup router
 	gin.SetMode(gin.TestMode)
@@ -57,6 +121,33 @@ func SetupTestRouter() *gin.Engine {
 // CleanupTestDB cleans up the test database
 func CleanupTestDB(db *gorm.DB) {
 	// Delete all data from tables
+	db.Exec("DELETE FROM daily_metrics")
+	db.Exec("DELETE FROM hourly_metrics")
+	db.Exec("DELETE FROM cart_sessions")
+	db.Exec("DELETE FROM invoices")
+	db.Exec("DELETE FROM item_view_counts")
+	db.Exec("DELETE FROM item_views")
+	db.Exec("DELETE FROM guest_tokens")
+	db.Exec("DELETE FROM item_recommendations")
+	db.Exec("DELETE FROM wishlist_items")
+	db.Exec("DELETE FROM wishlists")
+	db.Exec("DELETE FROM scheduled_prices")
+	db.Exec("DELETE FROM price_histories")
+	db.Exec("DELETE FROM item_images")
+	db.Exec("DELETE FROM reviews")
+	db.Exec("DELETE FROM order_items")
+	db.Exec("DELETE FROM variant_option_values")
+	db.Exec("DELETE FROM variants")
+	db.Exec("DELETE FROM option_values")
+	db.Exec("DELETE FROM option_types")
+	db.Exec("DELETE FROM categories")
+	db.Exec("DELETE FROM api_keys")
+	db.Exec("DELETE FROM oidc_login_states")
+	db.Exec("DELETE FROM user_identities")
+	db.Exec("DELETE FROM recovery_codes")
+	db.Exec("DELETE FROM email_verification_tokens")
+	db.Exec("DELETE FROM password_reset_tokens")
+	db.Exec("DELETE FROM idempotency_keys")
 	db.Exec("DELETE FROM orders")
 	db.Exec("DELETE FROM cart_items")
 	db.Exec("DELETE FROM carts")
@@ -105,6 +196,102 @@ func CreateTestUser(db *gorm.DB, username, password string) models.User {
 	return user
 }
 
+// SignupAndLogin registers a user through the API and returns a JWT for it
+func SignupAndLogin(router *gin.Engine, username, password string) string {
+	userData := map[string]interface{}{
+		"username": username,
+		"password": password,
+	}
+	jsonData, _ := json.Marshal(userData)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/users/login", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	token, _ := response["token"].(string)
+	return token
+}
+
+// SignupAdmin registers a user, promotes it to admin and returns a JWT for it
+func SignupAdmin(router *gin.Engine, db *gorm.DB, username, password string) string {
+	token := SignupAndLogin(router, username, password)
+	db.Model(&models.User{}).Where("username = ?", username).Update("role", models.RoleAdmin)
+	return token
+}
+
+// PerformRequest sends a JSON request to the router, optionally authenticated
+func PerformRequest(router *gin.Engine, method, path string, body interface{}, token string, headers map[string]string) *httptest.ResponseRecorder {
+	var reader *bytes.Buffer
+	if body != nil {
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(jsonData)
+	} else {
+		reader = bytes.NewBuffer(nil)
+	}
+
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// DecodeBody unmarshals a JSON response body into a map
+func DecodeBody(w *httptest.ResponseRecorder) map[string]interface{} {
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response
+}
+
+// UseTestMailer routes outgoing mail to files in a temporary directory
+func UseTestMailer(t *testing.T) string {
+	dir := t.TempDir()
+	utils.Mail = &utils.FileMailer{Dir: dir}
+	t.Cleanup(func() { utils.Mail = nil })
+	return dir
+}
+
+// UseTestStorage stores uploads in a temporary directory
+func UseTestStorage(t *testing.T) string {
+	dir := t.TempDir()
+	utils.Store = &utils.LocalStorage{Dir: dir, BaseURL: "/uploads"}
+	t.Cleanup(func() { utils.Store = nil })
+	return dir
+}
+
+// ReadMails returns the contents of every mail written to dir, oldest first
+func ReadMails(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read mail directory: %v", err)
+	}
+
+	var mails []string
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read mail: %v", err)
+		}
+		mails = append(mails, string(content))
+	}
+	return mails
+}
+
 // CreateTestCart creates a test cart for a user
 func CreateTestCart(db *gorm.DB, userID uint) models.Cart {
 	cart := models.Cart{
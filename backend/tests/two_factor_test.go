@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	token := SignupAndLogin(router, "totptest", "password123")
+	credentials := map[string]interface{}{"username": "totptest", "password": "password123"}
+
+	var secret string
+	var recoveryCodes []interface{}
+
+	t.Run("should enroll and confirm TOTP", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/me/2fa/enroll", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		response := DecodeBody(w)
+		secret = response["secret"].(string)
+		assert.Contains(t, response["otpauth_uri"], "otpauth://totp/")
+
+		w = PerformRequest(router, "POST", "/users/me/2fa/confirm", map[string]interface{}{"code": "000000"}, token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		code, _ := utils.TOTPCode(secret, time.Now())
+		w = PerformRequest(router, "POST", "/users/me/2fa/confirm", map[string]interface{}{"code": code}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		recoveryCodes = DecodeBody(w)["recovery_codes"].([]interface{})
+		assert.Equal(t, 10, len(recoveryCodes))
+	})
+
+	t.Run("should require a second step at login", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/login", credentials, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		response := DecodeBody(w)
+		assert.Equal(t, true, response["two_factor_required"])
+		assert.Nil(t, response["token"])
+		challenge := response["challenge_token"].(string)
+
+		// The challenge token is not a session token
+		w = PerformRequest(router, "GET", "/carts", nil, challenge, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// A TOTP code already used for confirmation cannot be replayed
+		code, _ := utils.TOTPCode(secret, time.Now())
+		w = PerformRequest(router, "POST", "/users/login/2fa", map[string]interface{}{"challenge_token": challenge, "code": code}, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// Use the next time step instead
+		code, _ = utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+		w = PerformRequest(router, "POST", "/users/login/2fa", map[string]interface{}{"challenge_token": challenge, "code": code}, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, DecodeBody(w)["token"])
+	})
+
+	t.Run("should not accept a code used by a parallel request", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/login", credentials, "", nil)
+		challenge := DecodeBody(w)["challenge_token"].(string)
+
+		// Free up the current step, then let another login use it right
+		// after this one has read the user
+		var user models.User
+		testDB.Where("username = ?", "totptest").First(&user)
+		testDB.Model(&user).Update("two_factor_last_step", 0)
+		used := false
+		testDB.Callback().Query().After("gorm:query").Register("test:parallel_totp", func(tx *gorm.DB) {
+			if used || tx.Statement.Table != "users" {
+				return
+			}
+			used = true
+			tx.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Where("id = ?", user.ID).
+				Update("two_factor_last_step", utils.TOTPStep(time.Now()))
+		})
+		defer testDB.Callback().Query().Remove("test:parallel_totp")
+
+		code, _ := utils.TOTPCode(secret, time.Now())
+		w = PerformRequest(router, "POST", "/users/login/2fa", map[string]interface{}{"challenge_token": challenge, "code": code}, "", nil)
+		assert.True(t, used)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should accept a recovery code only once", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/login", credentials, "", nil)
+		challenge := DecodeBody(w)["challenge_token"].(string)
+
+		body := map[string]interface{}{"challenge_token": challenge, "code": recoveryCodes[0]}
+		w = PerformRequest(router, "POST", "/users/login/2fa", body, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "POST", "/users/login/2fa", body, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAdminRequiredTwoFactor(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	adminToken := SignupAdmin(router, testDB, "totpadmin", "password123")
+	userToken := SignupAndLogin(router, "totpcustomer", "password123")
+
+	var user models.User
+	testDB.Where("username = ?", "totpcustomer").First(&user)
+
+	t.Run("should only let admins require 2FA", func(t *testing.T) {
+		w := PerformRequest(router, "PUT", "/admin/users/1/2fa", map[string]interface{}{"required": true}, userToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "GET", "/orders/all", nil, userToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should restrict a required account to enrollment", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/users/%d/2fa", user.ID)
+		w := PerformRequest(router, "PUT", path, map[string]interface{}{"required": true}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/carts", nil, userToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = PerformRequest(router, "POST", "/users/me/2fa/enroll", nil, userToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should require 2FA for admins when configured", func(t *testing.T) {
+		t.Setenv("REQUIRE_2FA_FOR_ADMINS", "true")
+
+		w := PerformRequest(router, "GET", "/orders/all", nil, adminToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Public routes treat the admin as anonymous, so drafts stay hidden
+		draft := models.Item{Name: "Unreleased", Price: 1, Status: models.ItemDraft}
+		testDB.Create(&draft)
+		w = PerformRequest(router, "GET", fmt.Sprintf("/items/%d", draft.ID), nil, adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
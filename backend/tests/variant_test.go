@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestVariants(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	adminToken := SignupAdmin(router, db, "variantadmin", "password123")
+	token := SignupAndLogin(router, "variantbuyer", "password123")
+
+	var sizeM, sizeL, red uint
+	var mediumRed, largeRed uint
+
+	t.Run("should create option types and variants", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items/1/options", map[string]interface{}{"name": "Size", "values": []string{"M", "L"}}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		values := DecodeBody(w)["option_type"].(map[string]interface{})["values"].([]interface{})
+		sizeM = uint(values[0].(map[string]interface{})["id"].(float64))
+		sizeL = uint(values[1].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "POST", "/items/1/options", map[string]interface{}{"name": "Color", "values": []string{"Red"}}, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		red = uint(DecodeBody(w)["option_type"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})["id"].(float64))
+
+		body := map[string]interface{}{"sku": "TEST-M-RED", "stock": 3, "option_value_ids": []uint{sizeM, red}}
+		w = PerformRequest(router, "POST", "/items/1/variants", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mediumRed = uint(DecodeBody(w)["variant"].(map[string]interface{})["id"].(float64))
+
+		body = map[string]interface{}{"sku": "TEST-L-RED", "price": 14.5, "stock": 5, "option_value_ids": []uint{sizeL, red}}
+		w = PerformRequest(router, "POST", "/items/1/variants", body, adminToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		largeRed = uint(DecodeBody(w)["variant"].(map[string]interface{})["id"].(float64))
+
+		// Duplicate SKU, duplicate combination and two sizes at once
+		w = PerformRequest(router, "POST", "/items/1/variants", map[string]interface{}{"sku": "TEST-M-RED", "stock": 1}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = PerformRequest(router, "POST", "/items/1/variants", map[string]interface{}{"sku": "OTHER", "option_value_ids": []uint{red, sizeM}}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = PerformRequest(router, "POST", "/items/1/variants", map[string]interface{}{"sku": "BAD", "option_value_ids": []uint{sizeM, sizeL}}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "POST", "/items/1/variants", map[string]interface{}{"sku": "NOPE"}, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should require a SKU and check it against the database", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/items/1/variants", map[string]interface{}{"sku": "  ", "stock": 1}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		path := fmt.Sprintf("/items/1/variants/%d", mediumRed)
+		w = PerformRequest(router, "PUT", path, map[string]interface{}{"sku": ""}, adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = PerformRequest(router, "PUT", path, map[string]interface{}{"sku": "TEST-L-RED"}, adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// A failing count is not mistaken for a free SKU or an item
+		// without variants
+		db.Callback().Query().Before("gorm:query").Register("test:fail_variant_count", func(tx *gorm.DB) {
+			if _, counting := tx.Statement.Dest.(*int64); counting && tx.Statement.Table == "variants" {
+				tx.AddError(errors.New("database unavailable"))
+			}
+		})
+		defer db.Callback().Query().Remove("test:fail_variant_count")
+		w = PerformRequest(router, "PUT", path, map[string]interface{}{"sku": "TEST-M-RED-2"}, adminToken, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = PerformRequest(router, "GET", "/items/1", nil, "", nil)
+		item := DecodeBody(w)["item"].(map[string]interface{})
+		assert.Equal(t, 2, len(item["option_types"].([]interface{})))
+		assert.Equal(t, 2, len(item["variants"].([]interface{})))
+	})
+
+	t.Run("should require a variant and respect stock", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 2, "variant_id": mediumRed, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "variant_id": mediumRed, "quantity": 2}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "variant_id": mediumRed, "quantity": 2}, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "variant_id": largeRed, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/carts", nil, token, nil)
+		lines := DecodeBody(w)["cart"].(map[string]interface{})["items"].([]interface{})
+		assert.Equal(t, 2, len(lines))
+	})
+
+	t.Run("should record the variant on the order", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.InDelta(t, 2*10.99+14.5, DecodeBody(w)["total"].(float64), 0.001)
+
+		var lines []models.OrderItem
+		db.Order("id").Find(&lines)
+		assert.Equal(t, 2, len(lines))
+		assert.Equal(t, mediumRed, *lines[0].VariantID)
+		assert.Equal(t, "TEST-M-RED", lines[0].SKU)
+		assert.Equal(t, 14.5, lines[1].Price)
+
+		var variant models.Variant
+		db.First(&variant, mediumRed)
+		assert.Equal(t, 1, variant.Stock)
+
+		w = PerformRequest(router, "GET", "/orders", nil, token, nil)
+		orders := DecodeBody(w)["orders"].([]interface{})
+		assert.Equal(t, 2, len(orders[0].(map[string]interface{})["items"].([]interface{})))
+	})
+
+	t.Run("should fail the order when stock ran out", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "variant_id": mediumRed, "quantity": 1}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		db.Model(&models.Variant{}).Where("id = ?", mediumRed).Update("stock", 0)
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var count int64
+		db.Model(&models.Order{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
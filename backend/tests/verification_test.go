@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"net/http"
+	"regexp"
+	"shopping-cart/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var verificationTokenPattern = regexp.MustCompile(`Verification token: ([0-9a-f]+)`)
+
+func TestEmailVerification(t *testing.T) {
+	t.Setenv("REQUIRE_VERIFIED_EMAIL_FOR_ORDERS", "true")
+
+	router := setupTestDB()
+	defer cleanupTestDB()
+	mailDir := UseTestMailer(t)
+
+	signup := map[string]interface{}{
+		"username": "verifytest",
+		"password": "password123",
+		"email":    "verify@example.com",
+	}
+	w := PerformRequest(router, "POST", "/users", signup, "", nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = PerformRequest(router, "POST", "/users/login", signup, "", nil)
+	token := DecodeBody(w)["token"].(string)
+
+	t.Run("should reject a duplicate email on signup", func(t *testing.T) {
+		other := map[string]interface{}{
+			"username": "verifyother",
+			"password": "password123",
+			"email":    "verify@example.com",
+		}
+		w := PerformRequest(router, "POST", "/users", other, "", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("should block orders until the email is verified", func(t *testing.T) {
+		PerformRequest(router, "POST", "/carts", map[string]interface{}{"item_id": 1, "quantity": 1}, token, nil)
+
+		w := PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should resend the verification email", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/verify/resend", nil, token, nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, 2, len(ReadMails(t, mailDir)))
+	})
+
+	t.Run("should reject an unknown token", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/users/verify", map[string]interface{}{"token": "nope"}, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should verify the email and allow orders", func(t *testing.T) {
+		mails := ReadMails(t, mailDir)
+		match := verificationTokenPattern.FindStringSubmatch(mails[len(mails)-1])
+		assert.Len(t, match, 2)
+
+		w := PerformRequest(router, "POST", "/users/verify", map[string]interface{}{"token": match[1]}, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/users/me", nil, token, nil)
+		user := DecodeBody(w)["user"].(map[string]interface{})
+		assert.Equal(t, true, user["email_verified"])
+
+		w = PerformRequest(router, "POST", "/orders", nil, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("should require verification again after changing the email", func(t *testing.T) {
+		w := PerformRequest(router, "PATCH", "/users/me", map[string]interface{}{"email": "new@example.com"}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		user := DecodeBody(w)["user"].(map[string]interface{})
+		assert.Equal(t, false, user["email_verified"])
+		assert.Equal(t, 3, len(ReadMails(t, mailDir)))
+	})
+
+	t.Run("should accept a token only once when used in parallel", func(t *testing.T) {
+		mails := ReadMails(t, mailDir)
+		match := verificationTokenPattern.FindStringSubmatch(mails[len(mails)-1])
+		assert.Len(t, match, 2)
+
+		// Let another request use the token right after this one looked it up
+		used := false
+		testDB.Callback().Query().After("gorm:query").Register("test:parallel_verify", func(tx *gorm.DB) {
+			if used || tx.Statement.Table != "email_verification_tokens" {
+				return
+			}
+			used = true
+			tx.Session(&gorm.Session{NewDB: true}).Model(&models.EmailVerificationToken{}).
+				Where("used_at IS NULL").Update("used_at", time.Now())
+		})
+		defer testDB.Callback().Query().Remove("test:parallel_verify")
+
+		w := PerformRequest(router, "POST", "/users/verify", map[string]interface{}{"token": match[1]}, "", nil)
+		assert.True(t, used)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
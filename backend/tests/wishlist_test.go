@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopping-cart/models"
+	"shopping-cart/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// failingMailer rejects every message
+type failingMailer struct{}
+
+func (failingMailer) Send(msg utils.MailMessage) error {
+	return errors.New("mail server unavailable")
+}
+
+func TestWishlist(t *testing.T) {
+	router := setupTestDB()
+	defer cleanupTestDB()
+
+	db := testDB
+	mailDir := UseTestMailer(t)
+	adminToken := SignupAdmin(router, db, "wishadmin", "password123")
+	token := SignupAndLogin(router, "wisher", "password123")
+	otherToken := SignupAndLogin(router, "otherwisher", "password123")
+
+	now := time.Now()
+	db.Model(&models.User{}).Where("username = ?", "wisher").
+		Updates(map[string]interface{}{"email": "wisher@example.com", "email_verified_at": now})
+
+	getWishlist := func(token string) []interface{} {
+		w := PerformRequest(router, "GET", "/wishlist", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return DecodeBody(w)["wishlist"].(map[string]interface{})["items"].([]interface{})
+	}
+
+	var entryID uint
+
+	t.Run("should add and list wishlist items", func(t *testing.T) {
+		assert.Empty(t, getWishlist(token))
+
+		w := PerformRequest(router, "POST", "/wishlist/items", map[string]interface{}{"item_id": 1}, token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		entryID = uint(DecodeBody(w)["item"].(map[string]interface{})["id"].(float64))
+
+		w = PerformRequest(router, "POST", "/wishlist/items", map[string]interface{}{"item_id": 1}, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = PerformRequest(router, "POST", "/wishlist/items", map[string]interface{}{"item_id": 999}, token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		PerformRequest(router, "POST", "/wishlist/items", map[string]interface{}{"item_id": 2}, token, nil)
+		items := getWishlist(token)
+		assert.Len(t, items, 2)
+		assert.Empty(t, getWishlist(otherToken))
+	})
+
+	t.Run("should not let other users touch an entry", func(t *testing.T) {
+		w := PerformRequest(router, "DELETE", fmt.Sprintf("/wishlist/items/%d", entryID), nil, otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should signal a price drop once", func(t *testing.T) {
+		w := PerformRequest(router, "PUT", "/items/1", map[string]interface{}{"price": 8.99}, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		entry := getWishlist(token)[1].(map[string]interface{})
+		assert.Equal(t, float64(1), entry["item_id"])
+		assert.Equal(t, 10.99, entry["added_price"])
+		assert.Equal(t, 8.99, entry["price"])
+		assert.Equal(t, true, entry["price_dropped"])
+
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		mails := ReadMails(t, mailDir)
+		assert.Len(t, mails, 1)
+		assert.Contains(t, mails[0], "To: wisher@example.com")
+		assert.Contains(t, mails[0], "dropped from 10.99 to 8.99")
+
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		assert.Len(t, ReadMails(t, mailDir), 1)
+	})
+
+	t.Run("should signal when an item is back in stock", func(t *testing.T) {
+		PerformRequest(router, "PUT", "/items/2", map[string]interface{}{"in_stock": false}, adminToken, nil)
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		assert.Len(t, ReadMails(t, mailDir), 1)
+
+		PerformRequest(router, "PUT", "/items/2", map[string]interface{}{"in_stock": true}, adminToken, nil)
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		mails := ReadMails(t, mailDir)
+		assert.Len(t, mails, 2)
+		assert.Contains(t, mails[1], "Test Item 2 is back in stock")
+	})
+
+	t.Run("should announce a change again after a failed email", func(t *testing.T) {
+		PerformRequest(router, "PUT", "/items/1", map[string]interface{}{"price": 7.99}, adminToken, nil)
+
+		utils.Mail = failingMailer{}
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		utils.Mail = &utils.FileMailer{Dir: mailDir}
+		assert.Len(t, ReadMails(t, mailDir), 2)
+
+		assert.NoError(t, utils.NotifyWishlistChanges(db, time.Now()))
+		mails := ReadMails(t, mailDir)
+		assert.Len(t, mails, 3)
+		assert.Contains(t, mails[2], "dropped from 8.99 to 7.99")
+	})
+
+	t.Run("should share a read-only view", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/wishlist/share", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		shareToken := DecodeBody(w)["share_token"].(string)
+
+		w = PerformRequest(router, "POST", "/wishlist/share", nil, token, nil)
+		assert.Equal(t, shareToken, DecodeBody(w)["share_token"])
+
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := DecodeBody(w)
+		assert.Equal(t, "wisher", body["owner"])
+		shared := body["wishlist"].(map[string]interface{})
+		assert.Len(t, shared["items"], 2)
+		assert.Nil(t, shared["share_url"])
+
+		w = PerformRequest(router, "DELETE", "/wishlist/share", nil, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should move an item into the cart", func(t *testing.T) {
+		w := PerformRequest(router, "POST", fmt.Sprintf("/wishlist/items/%d/move-to-cart", entryID), map[string]interface{}{"quantity": 2}, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/carts", nil, token, nil)
+		lines := DecodeBody(w)["cart"].(map[string]interface{})["items"].([]interface{})
+		assert.Len(t, lines, 1)
+		assert.Equal(t, float64(2), lines[0].(map[string]interface{})["quantity"])
+		assert.Len(t, getWishlist(token), 1)
+
+		// Archived items cannot be moved and stay on the wishlist
+		PerformRequest(router, "PUT", "/items/2", map[string]interface{}{"status": "archived"}, adminToken, nil)
+		entry := getWishlist(token)[0].(map[string]interface{})
+		assert.Equal(t, false, entry["in_stock"])
+		w = PerformRequest(router, "POST", fmt.Sprintf("/wishlist/items/%d/move-to-cart", uint(entry["id"].(float64))), nil, token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Len(t, getWishlist(token), 1)
+
+		// Shared views leave the archived item out
+		w = PerformRequest(router, "POST", "/wishlist/share", nil, token, nil)
+		shareToken := DecodeBody(w)["share_token"].(string)
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Empty(t, DecodeBody(w)["wishlist"].(map[string]interface{})["items"])
+	})
+
+	t.Run("should reject an entry added by a parallel request", func(t *testing.T) {
+		var wishlist models.Wishlist
+		db.Joins("JOIN users ON users.id = wishlists.user_id").Where("users.username = ?", "otherwisher").First(&wishlist)
+
+		// The other request inserts the same entry after this one checked
+		added := false
+		db.Callback().Query().After("gorm:query").Register("test:parallel_wishlist_add", func(tx *gorm.DB) {
+			if added || tx.Statement.Table != "wishlist_items" {
+				return
+			}
+			added = true
+			tx.Session(&gorm.Session{NewDB: true}).
+				Exec("INSERT INTO wishlist_items (wishlist_id, item_id, added_price, last_price, last_in_stock, created_at) VALUES (?, 1, 1, 1, true, ?)", wishlist.ID, time.Now())
+		})
+		defer db.Callback().Query().Remove("test:parallel_wishlist_add")
+
+		w := PerformRequest(router, "POST", "/wishlist/items", map[string]interface{}{"item_id": 1}, otherToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var count int64
+		db.Model(&models.WishlistItem{}).Where("wishlist_id = ? AND item_id = ?", wishlist.ID, 1).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("should stop sharing the wishlist of a deleted account", func(t *testing.T) {
+		w := PerformRequest(router, "POST", "/wishlist/share", nil, otherToken, nil)
+		shareToken := DecodeBody(w)["share_token"].(string)
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var user models.User
+		db.Where("username = ?", "otherwisher").First(&user)
+		w = PerformRequest(router, "DELETE", "/users/me", nil, otherToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		var count int64
+		db.Model(&models.Wishlist{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+
+		// Accounts deleted before wishlists were removed kept their link
+		db.Create(&models.Wishlist{UserID: user.ID, ShareToken: &shareToken})
+		w = PerformRequest(router, "GET", "/wishlists/shared/"+shareToken, nil, "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,414 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"shopping-cart/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CatalogFormatCSV   = "csv"
+	CatalogFormatJSONL = "jsonl"
+)
+
+// ErrUnknownCatalogFormat is returned for formats other than csv and jsonl
+var ErrUnknownCatalogFormat = errors.New("format must be csv or jsonl")
+
+// catalogColumns are the CSV columns, in export order
+var catalogColumns = []string{"sku", "name", "description", "price", "category", "image", "in_stock"}
+
+// generatedSKUPrefix prefixes the SKU exported for items without one, so
+// an export can be imported back onto the same items
+const generatedSKUPrefix = "ITEM-"
+
+// CatalogRow is one item in an import or export file. Optional fields are
+// nil when their CSV column or JSON key is missing, and updates leave them
+// unchanged.
+type CatalogRow struct {
+	SKU         string  `json:"sku"`
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Price       float64 `json:"price"`
+	Category    *string `json:"category"`
+	Image       *string `json:"image"`
+	InStock     *bool   `json:"in_stock"`
+}
+
+// CatalogRowError lists the problems with one row. Row is 1-based and
+// counts data rows, not the CSV header.
+type CatalogRowError struct {
+	Row    int      `json:"row"`
+	SKU    string   `json:"sku,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// CatalogImportResult summarizes an import. Nothing is written when Errors
+// is not empty or DryRun is set.
+type CatalogImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []CatalogRowError `json:"errors"`
+}
+
+// ParseCatalog reads catalog rows from CSV with a header line or from JSON
+// Lines. Rows that cannot be parsed are reported as row errors.
+func ParseCatalog(r io.Reader, format string) ([]CatalogRow, []CatalogRowError, error) {
+	switch format {
+	case CatalogFormatCSV:
+		return parseCatalogCSV(r)
+	case CatalogFormatJSONL:
+		return parseCatalogJSONL(r)
+	default:
+		return nil, nil, ErrUnknownCatalogFormat
+	}
+}
+
+func parseCatalogCSV(r io.Reader) ([]CatalogRow, []CatalogRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price"} {
+		if _, ok := index[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	var rows []CatalogRow
+	var rowErrors []CatalogRowError
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, CatalogRowError{Row: n, Errors: []string{err.Error()}})
+			rows = append(rows, CatalogRow{})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		optional := func(name string) *string {
+			if _, ok := index[name]; !ok {
+				return nil
+			}
+			value := field(name)
+			return &value
+		}
+
+		row := CatalogRow{
+			SKU:         field("sku"),
+			Name:        field("name"),
+			Description: optional("description"),
+			Category:    optional("category"),
+			Image:       optional("image"),
+		}
+		var problems []string
+		if price := field("price"); price != "" {
+			if row.Price, err = strconv.ParseFloat(price, 64); err != nil {
+				problems = append(problems, "price is not a number")
+			}
+		}
+		if inStock := field("in_stock"); inStock != "" {
+			value, err := strconv.ParseBool(inStock)
+			if err != nil {
+				problems = append(problems, "in_stock must be true or false")
+			} else {
+				row.InStock = &value
+			}
+		}
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, CatalogRowError{Row: n, SKU: row.SKU, Errors: problems})
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func parseCatalogJSONL(r io.Reader) ([]CatalogRow, []CatalogRowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []CatalogRow
+	var rowErrors []CatalogRowError
+	n := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		n++
+
+		var row CatalogRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			rowErrors = append(rowErrors, CatalogRowError{Row: n, Errors: []string{"invalid JSON: " + err.Error()}})
+		}
+		row.SKU = strings.TrimSpace(row.SKU)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return rows, rowErrors, nil
+}
+
+// ValidateCatalogRows checks required fields and duplicate SKUs
+func ValidateCatalogRows(rows []CatalogRow) []CatalogRowError {
+	var rowErrors []CatalogRowError
+	seen := map[string]int{}
+	for i, row := range rows {
+		var problems []string
+		if row.SKU == "" {
+			problems = append(problems, "sku is required")
+		} else if first, ok := seen[row.SKU]; ok {
+			problems = append(problems, fmt.Sprintf("sku duplicates row %d", first))
+		} else {
+			seen[row.SKU] = i + 1
+		}
+		if strings.TrimSpace(row.Name) == "" {
+			problems = append(problems, "name is required")
+		}
+		if row.Price <= 0 {
+			problems = append(problems, "price must be greater than 0")
+		}
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, CatalogRowError{Row: i + 1, SKU: row.SKU, Errors: problems})
+		}
+	}
+	return rowErrors
+}
+
+// ImportCatalog upserts items by SKU in a single transaction. Rows are
+// validated first and nothing is written if any row is invalid or dryRun
+// is set; the result then only reports what would change.
+func ImportCatalog(db *gorm.DB, rows []CatalogRow, parseErrors []CatalogRowError, dryRun bool) (CatalogImportResult, error) {
+	result := CatalogImportResult{DryRun: dryRun, Rows: len(rows)}
+	result.Errors = mergeRowErrors(parseErrors, ValidateCatalogRows(rows))
+
+	apply := len(result.Errors) == 0 && !dryRun
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			item, exists, err := findCatalogItem(tx, row.SKU)
+			if err != nil {
+				return err
+			}
+			if exists {
+				result.Updated++
+			} else {
+				result.Created++
+			}
+			if !apply {
+				continue
+			}
+
+			var categoryID *uint
+			categoryName := ""
+			if row.Category != nil && Slugify(*row.Category) != "" {
+				category, err := EnsureCategory(tx, *row.Category)
+				if err != nil {
+					return err
+				}
+				categoryID = &category.ID
+				categoryName = category.Name
+			}
+
+			if !exists {
+				inStock := row.InStock == nil || *row.InStock
+				item = models.Item{
+					SKU:        row.SKU,
+					Name:       strings.TrimSpace(row.Name),
+					Price:      row.Price,
+					Category:   categoryName,
+					CategoryID: categoryID,
+					InStock:    inStock,
+				}
+				if row.Description != nil {
+					item.Description = *row.Description
+				}
+				if row.Image != nil {
+					item.Image = *row.Image
+				}
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+				if err := RecordPriceChange(tx, item.ID, nil, item.Price, models.PriceReasonImport, nil, now); err != nil {
+					return err
+				}
+				// in_stock defaults to true in the schema, so false needs its own update
+				if !inStock {
+					if err := tx.Model(&item).Update("in_stock", false).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			updates := map[string]interface{}{
+				"sku":        row.SKU,
+				"name":       strings.TrimSpace(row.Name),
+				"price":      row.Price,
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			}
+			if row.Description != nil {
+				updates["description"] = *row.Description
+			}
+			if row.Category != nil {
+				updates["category"] = categoryName
+				updates["category_id"] = categoryID
+			}
+			if row.Image != nil {
+				updates["image"] = *row.Image
+			}
+			if row.InStock != nil {
+				updates["in_stock"] = *row.InStock
+			}
+			if err := tx.Unscoped().Model(&models.Item{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if row.Price != item.Price {
+				previous := item.Price
+				if err := RecordPriceChange(tx, item.ID, &previous, row.Price, models.PriceReasonImport, nil, now); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// findCatalogItem looks up the item an imported SKU refers to. Soft deleted
+// items are included so they are brought back, and a generated SKU from an
+// export matches the item without a SKU it was made for.
+func findCatalogItem(tx *gorm.DB, sku string) (models.Item, bool, error) {
+	var item models.Item
+	err := tx.Unscoped().Where("sku = ?", sku).First(&item).Error
+	if err == gorm.ErrRecordNotFound && strings.HasPrefix(sku, generatedSKUPrefix) {
+		if id, parseErr := strconv.ParseUint(strings.TrimPrefix(sku, generatedSKUPrefix), 10, 64); parseErr == nil {
+			err = tx.Unscoped().Where("id = ? AND sku = ''", id).First(&item).Error
+		}
+	}
+	if err == gorm.ErrRecordNotFound {
+		return item, false, nil
+	}
+	return item, err == nil, err
+}
+
+func mergeRowErrors(lists ...[]CatalogRowError) []CatalogRowError {
+	byRow := map[int]*CatalogRowError{}
+	var order []int
+	for _, list := range lists {
+		for _, rowError := range list {
+			if existing, ok := byRow[rowError.Row]; ok {
+				existing.Errors = append(existing.Errors, rowError.Errors...)
+				continue
+			}
+			copied := rowError
+			byRow[rowError.Row] = &copied
+			order = append(order, rowError.Row)
+		}
+	}
+
+	merged := []CatalogRowError{}
+	for _, row := range order {
+		merged = append(merged, *byRow[row])
+	}
+	return merged
+}
+
+// ExportCatalog streams every item to w in batches so large catalogs are
+// never held in memory at once
+func ExportCatalog(db *gorm.DB, w io.Writer, format string) error {
+	var writeRow func(row CatalogRow) error
+	var flush func() error
+
+	switch format {
+	case CatalogFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(catalogColumns); err != nil {
+			return err
+		}
+		writeRow = func(row CatalogRow) error {
+			return writer.Write([]string{
+				row.SKU,
+				row.Name,
+				*row.Description,
+				strconv.FormatFloat(row.Price, 'f', -1, 64),
+				*row.Category,
+				*row.Image,
+				strconv.FormatBool(row.InStock != nil && *row.InStock),
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case CatalogFormatJSONL:
+		encoder := json.NewEncoder(w)
+		writeRow = func(row CatalogRow) error { return encoder.Encode(row) }
+		flush = func() error { return nil }
+	default:
+		return ErrUnknownCatalogFormat
+	}
+
+	var items []models.Item
+	result := db.Order("id").FindInBatches(&items, 500, func(tx *gorm.DB, batch int) error {
+		for _, item := range items {
+			item := item
+			row := CatalogRow{
+				SKU:         item.SKU,
+				Name:        item.Name,
+				Description: &item.Description,
+				Price:       item.Price,
+				Category:    &item.Category,
+				Image:       &item.Image,
+				InStock:     &item.InStock,
+			}
+			if row.SKU == "" {
+				row.SKU = generatedSKUPrefix + strconv.FormatUint(uint64(item.ID), 10)
+			}
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+		return flush()
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return flush()
+}
+
+// ItemSKUTaken reports whether another item, including a deleted one that
+// an import would bring back, already uses the SKU
+func ItemSKUTaken(db *gorm.DB, sku string, exceptID uint) bool {
+	var count int64
+	db.Unscoped().Model(&models.Item{}).Where("sku = ? AND id <> ?", sku, exceptID).Count(&count)
+	return count > 0
+}
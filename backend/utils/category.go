@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"log"
+	"shopping-cart/models"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Slugify lowercases a name and joins its words with dashes, so
+// "Food & Beverages" becomes "food-beverages"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// EnsureCategory returns the category whose slug matches the name,
+// creating a top-level category if there is none yet
+func EnsureCategory(db *gorm.DB, name string) (models.Category, error) {
+	var category models.Category
+	slug := Slugify(name)
+	err := db.Where("slug = ?", slug).First(&category).Error
+	if err == nil {
+		return category, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return category, err
+	}
+
+	category = models.Category{Name: strings.TrimSpace(name), Slug: slug}
+	err = db.Create(&category).Error
+	return category, err
+}
+
+// MigrateItemCategories turns the free-text category strings of items that
+// are not linked to a category yet into category rows. Names differing only
+// in case or punctuation share one category.
+func MigrateItemCategories(db *gorm.DB) error {
+	var names []string
+	if err := db.Model(&models.Item{}).
+		Where("category_id IS NULL AND category <> ''").
+		Distinct().Pluck("category", &names).Error; err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if Slugify(name) == "" {
+			continue
+		}
+		category, err := EnsureCategory(db, name)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Item{}).
+			Where("category_id IS NULL AND category = ?", name).
+			Updates(map[string]interface{}{"category_id": category.ID, "category": category.Name}).Error; err != nil {
+			return err
+		}
+		log.Printf("Linked items in %q to category %s", name, category.Slug)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns the environment variable or the given default
+func GetEnv(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvInt returns the environment variable as an int or the given default
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvFloat returns the environment variable as a float or the given default
+func GetEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvBool returns the environment variable as a bool or the given default
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvSeconds returns the environment variable, in seconds, as a duration
+func GetEnvSeconds(key string, fallback time.Duration) time.Duration {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return time.Duration(value) * time.Second
+}
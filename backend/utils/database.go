@@ -4,8 +4,11 @@ import (
 	"log"
 	"os"
 	"shopping-cart/models"
+	"strings"
+	"time"
 
 	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -27,7 +30,13 @@ func InitDB() {
 	
 	// Initialize database based on type
 	if dbType == "sqlite" {
-		DB, err = gorm.Open(sqlite.Open(dbName), &gorm.Config{
+		// Concurrent writers wait for the lock instead of failing, and
+		// transactions take it up front so they can't fail halfway through
+		dsn := dbName
+		if !strings.Contains(dsn, "?") {
+			dsn += "?_pragma=busy_timeout(5000)&_txlock=immediate"
+		}
+		DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
 			DisableForeignKeyConstraintWhenMigrating: true,
 		})
 	} else {
@@ -46,13 +55,152 @@ func InitDB() {
 		&models.Cart{},
 		&models.CartItem{},
 		&models.Order{},
+		&models.IdempotencyKey{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIKey{},
+		&models.Category{},
+		&models.OptionType{},
+		&models.OptionValue{},
+		&models.Variant{},
+		&models.OrderItem{},
+		&models.Review{},
+		&models.ItemImage{},
+		&models.PriceHistory{},
+		&models.ScheduledPrice{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.ItemRecommendation{},
+		&models.ItemView{},
+		&models.GuestToken{},
+		&models.ItemViewCount{},
+		&models.Invoice{},
+		&models.CartSession{},
+		&models.HourlyMetric{},
+		&models.DailyMetric{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := EnsureIndexes(DB); err != nil {
+		log.Fatal("Failed to create indexes:", err)
+	}
 
 	// Seed initial data
 	seedData()
+	seedAdmin()
+
+	// Link free-text item categories to category rows
+	if err := MigrateItemCategories(DB); err != nil {
+		log.Println("Failed to migrate item categories:", err)
+	}
+
+	// Ratings only come from approved reviews
+	if err := RecomputeItemRatings(DB); err != nil {
+		log.Println("Failed to recompute item ratings:", err)
+	}
+
+	// Orders paid before invoices existed get theirs
+	if err := BackfillInvoices(DB, time.Now()); err != nil {
+		log.Println("Failed to backfill invoices:", err)
+	}
+}
+
+// EnsureIndexes creates the unique indexes that struct tags can't express
+// because they cover nullable columns or only some rows. Duplicates left by
+// earlier versions are merged first so the indexes can be built.
+func EnsureIndexes(db *gorm.DB) error {
+	statements := []string{
+		// Move lines of extra active carts into the user's oldest one
+		`UPDATE cart_items SET cart_id = (
+			SELECT MIN(keep.id) FROM carts keep JOIN carts extra ON extra.user_id = keep.user_id
+			WHERE extra.id = cart_items.cart_id AND keep.status = 'active' AND keep.deleted_at IS NULL
+		) WHERE cart_id IN (
+			SELECT id FROM carts extra WHERE status = 'active' AND deleted_at IS NULL AND id > (
+				SELECT MIN(keep.id) FROM carts keep WHERE keep.user_id = extra.user_id AND keep.status = 'active' AND keep.deleted_at IS NULL
+			)
+		)`,
+		`UPDATE carts SET status = 'merged' WHERE status = 'active' AND deleted_at IS NULL AND id > (
+			SELECT MIN(keep.id) FROM carts keep WHERE keep.user_id = carts.user_id AND keep.status = 'active' AND keep.deleted_at IS NULL
+		)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_user ON carts (user_id) WHERE status = 'active' AND deleted_at IS NULL",
+
+		// Add up duplicate lines for the same item and variant
+		`UPDATE cart_items SET quantity = (
+			SELECT SUM(dup.quantity) FROM cart_items dup
+			WHERE dup.cart_id = cart_items.cart_id AND dup.item_id = cart_items.item_id AND COALESCE(dup.variant_id, 0) = COALESCE(cart_items.variant_id, 0)
+		) WHERE id IN (SELECT MIN(id) FROM cart_items GROUP BY cart_id, item_id, COALESCE(variant_id, 0) HAVING COUNT(*) > 1)`,
+		"DELETE FROM cart_items WHERE id NOT IN (SELECT MIN(id) FROM cart_items GROUP BY cart_id, item_id, COALESCE(variant_id, 0))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_line ON cart_items (cart_id, item_id, COALESCE(variant_id, 0))",
+
+		// Keep the first wishlist entry for the same item and variant
+		"DELETE FROM wishlist_items WHERE id NOT IN (SELECT MIN(id) FROM wishlist_items GROUP BY wishlist_id, item_id, COALESCE(variant_id, 0))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlist_items_entry ON wishlist_items (wishlist_id, item_id, COALESCE(variant_id, 0))",
+
+		// One review per customer and item, counting reviews removed by a
+		// moderator. Keep the oldest live review, or else the oldest one.
+		`DELETE FROM reviews WHERE id NOT IN (
+			SELECT COALESCE(MIN(CASE WHEN deleted_at IS NULL THEN id END), MIN(id)) FROM reviews GROUP BY item_id, user_id
+		)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_item_user ON reviews (item_id, user_id)",
+
+		// Keep the first item with a SKU and suffix the others with their ID.
+		// Deleted items are included since an import brings them back by SKU.
+		`UPDATE items SET sku = sku || '-' || id WHERE sku <> '' AND id > (
+			SELECT MIN(keep.id) FROM items keep WHERE keep.sku = items.sku
+		)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_items_unique_sku ON items (sku) WHERE sku <> ''",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsUniqueViolation reports whether err comes from a unique index
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// seedAdmin creates the admin account named by ADMIN_USERNAME and
+// ADMIN_PASSWORD, or promotes it if the user already exists
+func seedAdmin() {
+	username := os.Getenv("ADMIN_USERNAME")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return
+	}
+
+	var user models.User
+	if err := DB.Where("username = ?", username).First(&user).Error; err == nil {
+		if user.Role != models.RoleAdmin {
+			DB.Model(&user).Update("role", models.RoleAdmin)
+			log.Printf("Promoted %s to admin", username)
+		}
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Failed to hash admin password:", err)
+		return
+	}
+
+	user = models.User{
+		Username: username,
+		Password: string(hashedPassword),
+		Role:     models.RoleAdmin,
+	}
+	if err := DB.Create(&user).Error; err != nil {
+		log.Println("Failed to create admin user:", err)
+		return
+	}
+	log.Printf("Admin user %s created", username)
 }
 
 func seedData() {
@@ -70,8 +218,6 @@ func seedData() {
 			Description: "High-quality wireless headphones with active noise cancellation and 30-hour battery life",
 			Price:       299.99,
 			Category:    "Electronics",
-			Rating:      4.8,
-			Reviews:     1247,
 			Image:       "https://images.unsplash.com/photo-1505740420928-5e560c06d30e?w=400&h=300&fit=crop",
 			InStock:     true,
 		},
@@ -80,8 +226,6 @@ func seedData() {
 			Description: "Advanced fitness tracking with heart rate monitoring, GPS, and 7-day battery life",
 			Price:       199.99,
 			Category:    "Electronics",
-			Rating:      4.6,
-			Reviews:     892,
 			Image:       "https://images.unsplash.com/photo-1523275335684-37898b6baf30?w=400&h=300&fit=crop",
 			InStock:     true,
 		},
@@ -90,8 +234,6 @@ func seedData() {
 			Description: "Premium ergonomic office chair with adjustable lumbar support and memory foam cushion",
 			Price:       449.99,
 			Category:    "Furniture",
-			Rating:      4.7,
-			Reviews:     456,
 			Image:       "https://images.unsplash.com/photo-1567538096630-e0c55bd6374c?w=400&h=300&fit=crop",
 			InStock:     true,
 		},
@@ -100,8 +242,6 @@ func seedData() {
 			Description: "Premium organic coffee beans from sustainable farms in Colombia",
 			Price:       24.99,
 			Category:    "Food & Beverages",
-			Rating:      4.9,
-			Reviews:     2341,
 			Image:       "https://images.unsplash.com/photo-1559056199-641a0ac8b55e?w=400&h=300&fit=crop",
 			InStock:     true,
 		},
@@ -110,8 +250,6 @@ func seedData() {
 			Description: "85mm f/1.4 portrait lens with beautiful bokeh and exceptional sharpness",
 			Price:       899.99,
 			Category:    "Electronics",
-			Rating:      4.9,
-			Reviews:     567,
 			Image:       "https://images.unsplash.com/photo-1516035069371-29a1b244cc32?w=400&h=300&fit=crop",
 			InStock:     true,
 		},
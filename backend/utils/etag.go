@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidIfMatch is returned when an If-Match header cannot be parsed
+var ErrInvalidIfMatch = errors.New("invalid If-Match header")
+
+// FormatETag renders a resource version as a strong ETag
+func FormatETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// ParseIfMatch extracts the expected version from an If-Match header.
+// The boolean is false when the header is absent or "*", meaning no
+// version precondition applies.
+func ParseIfMatch(header string) (uint, bool, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	header = strings.TrimPrefix(header, "W/")
+	if len(header) < 2 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return 0, false, ErrInvalidIfMatch
+	}
+
+	version, err := strconv.ParseUint(header[1:len(header)-1], 10, 32)
+	if err != nil {
+		return 0, false, ErrInvalidIfMatch
+	}
+	return uint(version), true, nil
+}
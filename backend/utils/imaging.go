@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrImageTooLarge    = errors.New("image dimensions too large")
+)
+
+// imageTypes maps the accepted content types to file extensions
+var imageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// maxImagePixels guards against decompression bombs
+const maxImagePixels = 40_000_000
+
+// ImageExtension returns the file extension for an accepted image content
+// type, or false if the type is not accepted
+func ImageExtension(contentType string) (string, bool) {
+	ext, ok := imageTypes[contentType]
+	return ext, ok
+}
+
+// ThumbnailSizes returns the bounding box sizes from IMAGE_THUMBNAIL_SIZES,
+// a comma separated list of pixel sizes
+func ThumbnailSizes() []int {
+	var sizes []int
+	for _, part := range strings.Split(GetEnv("IMAGE_THUMBNAIL_SIZES", "150,400,800"), ",") {
+		if size, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && size > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+// DecodeImage checks the dimensions of an image before decoding it
+func DecodeImage(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	return img, format, nil
+}
+
+// Thumbnail scales an image to fit within a size by size box, keeping its
+// aspect ratio. Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// EncodeThumbnail encodes a thumbnail as PNG for formats that may carry
+// transparency and as JPEG otherwise, returning the bytes and extension
+func EncodeThumbnail(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "png", "gif", "webp":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "png", nil
+	default:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "jpg", nil
+	}
+}
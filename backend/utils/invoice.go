@@ -0,0 +1,388 @@
+package utils
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"shopping-cart/models"
+	"shopping-cart/pdf"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InvoiceStatuses are the order statuses that mean the order has been paid
+var InvoiceStatuses = []string{models.OrderPaid, models.OrderShipped, models.OrderDelivered}
+
+// IsInvoiceStatus reports whether an order in status has an invoice
+func IsInvoiceStatus(status string) bool {
+	for _, s := range InvoiceStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// configText reads a multi-line setting, where "\n" separates lines
+func configText(key string) string {
+	return strings.ReplaceAll(GetEnv(key, ""), `\n`, "\n")
+}
+
+// maxInvoiceAttempts bounds how often IssueInvoice retries after a
+// concurrent payment took the next sequence number
+const maxInvoiceAttempts = 5
+
+// IssueInvoice gives the order an invoice with the next sequence number,
+// or returns the one it already has. Run it inside the transaction that
+// marks the order as paid; a rolled back payment then frees its number.
+// Prices are taken to include tax at INVOICE_TAX_RATE.
+func IssueInvoice(tx *gorm.DB, order models.Order, now time.Time) (models.Invoice, error) {
+	var invoice models.Invoice
+	for attempt := 1; ; attempt++ {
+		// The savepoint undoes a conflicting insert without rolling back the
+		// caller's transaction, and the retry reads the new highest sequence
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			var err error
+			invoice, err = issueInvoice(tx, order, now)
+			return err
+		})
+		if !IsUniqueViolation(err) || attempt == maxInvoiceAttempts {
+			return invoice, err
+		}
+	}
+}
+
+func issueInvoice(tx *gorm.DB, order models.Order, now time.Time) (models.Invoice, error) {
+	var invoice models.Invoice
+	if err := tx.Where("order_id = ?", order.ID).First(&invoice).Error; err == nil {
+		return invoice, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return invoice, err
+	}
+
+	var lines []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id").Find(&lines).Error; err != nil {
+		return invoice, err
+	}
+	var user models.User
+	if err := tx.Unscoped().First(&user, order.UserID).Error; err != nil {
+		return invoice, err
+	}
+
+	var last uint
+	if err := tx.Model(&models.Invoice{}).Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error; err != nil {
+		return invoice, err
+	}
+
+	rate := GetEnvFloat("INVOICE_TAX_RATE", 0)
+	var subtotal, tax float64
+	for _, line := range lines {
+		net, lineTax := splitTax(line.Price*float64(line.Quantity), rate)
+		subtotal += net
+		tax += lineTax
+	}
+
+	customerName := user.DisplayName
+	if customerName == "" {
+		customerName = user.Username
+	}
+
+	invoice = models.Invoice{
+		OrderID:         order.ID,
+		Sequence:        last + 1,
+		Number:          fmt.Sprintf("%s%06d", GetEnv("INVOICE_NUMBER_PREFIX", "INV-"), last+1),
+		IssuedAt:        now,
+		Currency:        GetEnv("INVOICE_CURRENCY", "USD"),
+		TaxName:         GetEnv("INVOICE_TAX_NAME", "Tax"),
+		TaxRate:         rate,
+		Subtotal:        roundMoney(subtotal),
+		TaxAmount:       roundMoney(tax),
+		Total:           roundMoney(subtotal + tax),
+		SellerName:      GetEnv("SELLER_NAME", "Shopping Cart"),
+		SellerAddress:   configText("SELLER_ADDRESS"),
+		SellerTaxID:     GetEnv("SELLER_TAX_ID", ""),
+		SellerEmail:     GetEnv("SELLER_EMAIL", ""),
+		CustomerName:    customerName,
+		CustomerEmail:   user.Email,
+		BillingAddress:  order.BillingAddress,
+		ShippingAddress: order.ShippingAddress,
+	}
+	return invoice, tx.Create(&invoice).Error
+}
+
+// BackfillInvoices issues invoices for paid orders that don't have one,
+// such as orders paid before invoices existed. They are numbered in the
+// order they were last updated, the closest record of when they were paid.
+func BackfillInvoices(db *gorm.DB, now time.Time) error {
+	var orders []models.Order
+	if err := db.Where("status IN ? AND id NOT IN (?)", InvoiceStatuses, db.Model(&models.Invoice{}).Select("order_id")).
+		Order("updated_at, id").Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := IssueInvoice(tx, order, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(orders) > 0 {
+		log.Printf("Issued invoices for %d paid orders", len(orders))
+	}
+	return nil
+}
+
+// splitTax splits a tax inclusive amount into net and tax
+func splitTax(gross float64, rate float64) (float64, float64) {
+	gross = roundMoney(gross)
+	net := roundMoney(gross / (1 + rate))
+	return net, roundMoney(gross - net)
+}
+
+// InvoiceLine is an order line with its tax split out
+type InvoiceLine struct {
+	Name      string
+	SKU       string
+	Quantity  int
+	UnitPrice string
+	Net       string
+	Tax       string
+	Total     string
+}
+
+// InvoiceView is an invoice formatted for rendering
+type InvoiceView struct {
+	Invoice  models.Invoice
+	Order    models.Order
+	Lines    []InvoiceLine
+	TaxLabel string
+	Subtotal string
+	Tax      string
+	Total    string
+}
+
+// NewInvoiceView formats an invoice and the lines of its order
+func NewInvoiceView(invoice models.Invoice, order models.Order) InvoiceView {
+	money := func(amount float64) string {
+		return fmt.Sprintf("%.2f %s", amount, invoice.Currency)
+	}
+
+	view := InvoiceView{
+		Invoice:  invoice,
+		Order:    order,
+		TaxLabel: fmt.Sprintf("%s %g%%", invoice.TaxName, roundMoney(invoice.TaxRate*100)),
+		Subtotal: money(invoice.Subtotal),
+		Tax:      money(invoice.TaxAmount),
+		Total:    money(invoice.Total),
+	}
+	for _, line := range order.Items {
+		gross := line.Price * float64(line.Quantity)
+		net, tax := splitTax(gross, invoice.TaxRate)
+		view.Lines = append(view.Lines, InvoiceLine{
+			Name:      line.Name,
+			SKU:       line.SKU,
+			Quantity:  line.Quantity,
+			UnitPrice: money(line.Price),
+			Net:       money(net),
+			Tax:       money(tax),
+			Total:     money(roundMoney(gross)),
+		})
+	}
+	return view
+}
+
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"lines": func(text string) []string { return strings.Split(text, "\n") },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Invoice.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 800px; margin: 40px auto; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+.num { text-align: right; }
+.parties { display: flex; justify-content: space-between; margin: 24px 0; }
+.totals td { border: none; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Invoice.Number}}</h1>
+<p>Issued {{date .Invoice.IssuedAt}} &middot; Order #{{.Order.ID}} of {{date .Order.CreatedAt}}</p>
+<div class="parties">
+<div>
+<strong>{{.Invoice.SellerName}}</strong><br>
+{{range lines .Invoice.SellerAddress}}{{.}}<br>{{end}}
+{{if .Invoice.SellerTaxID}}Tax ID: {{.Invoice.SellerTaxID}}<br>{{end}}
+{{if .Invoice.SellerEmail}}{{.Invoice.SellerEmail}}{{end}}
+</div>
+<div>
+<strong>Bill to</strong><br>
+{{.Invoice.CustomerName}}<br>
+{{range lines .Invoice.BillingAddress}}{{.}}<br>{{end}}
+{{if .Invoice.CustomerEmail}}{{.Invoice.CustomerEmail}}{{end}}
+</div>
+{{if .Invoice.ShippingAddress}}<div>
+<strong>Ship to</strong><br>
+{{range lines .Invoice.ShippingAddress}}{{.}}<br>{{end}}
+</div>{{end}}
+</div>
+<table>
+<thead><tr><th>Item</th><th>SKU</th><th class="num">Qty</th><th class="num">Unit price</th><th class="num">Net</th><th class="num">{{.Invoice.TaxName}}</th><th class="num">Total</th></tr></thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Name}}</td><td>{{.SKU}}</td><td class="num">{{.Quantity}}</td><td class="num">{{.UnitPrice}}</td><td class="num">{{.Net}}</td><td class="num">{{.Tax}}</td><td class="num">{{.Total}}</td></tr>
+{{end}}</tbody>
+</table>
+<table class="totals">
+<tr><td class="num">Subtotal</td><td class="num">{{.Subtotal}}</td></tr>
+<tr><td class="num">{{.TaxLabel}}</td><td class="num">{{.Tax}}</td></tr>
+<tr><td class="num"><strong>Total</strong></td><td class="num"><strong>{{.Total}}</strong></td></tr>
+</table>
+</body>
+</html>
+`))
+
+// RenderInvoiceHTML writes the invoice as an HTML page
+func RenderInvoiceHTML(w io.Writer, view InvoiceView) error {
+	return invoiceTemplate.Execute(w, view)
+}
+
+// RenderInvoicePDF writes the invoice as an A4 PDF, continuing the line
+// table on new pages as needed
+func RenderInvoicePDF(w io.Writer, view InvoiceView) error {
+	const (
+		left   = 50.0
+		right  = pdf.PageWidth - 50
+		bottom = pdf.PageHeight - 60
+		size   = 9.0
+	)
+	columns := []struct {
+		title string
+		x     float64
+		right bool
+	}{
+		{"Item", left, false},
+		{"SKU", 230, false},
+		{"Qty", 320, true},
+		{"Unit price", 385, true},
+		{"Net", 445, true},
+		{view.Invoice.TaxName, 500, true},
+		{"Total", right, true},
+	}
+
+	doc := pdf.New()
+	page := doc.AddPage()
+	y := 60.0
+	page.Text(left, y, 20, true, "Invoice "+view.Invoice.Number)
+	y += 18
+	page.Text(left, y, size, false, fmt.Sprintf("Issued %s - Order #%d of %s",
+		view.Invoice.IssuedAt.Format("2006-01-02"), view.Order.ID, view.Order.CreatedAt.Format("2006-01-02")))
+
+	// Seller, billing and shipping details side by side
+	y += 30
+	blocks := [][]string{
+		append([]string{view.Invoice.SellerName}, nonEmpty(strings.Split(view.Invoice.SellerAddress, "\n"), prefixed("Tax ID: ", view.Invoice.SellerTaxID), view.Invoice.SellerEmail)...),
+		append([]string{"Bill to", view.Invoice.CustomerName}, nonEmpty(strings.Split(view.Invoice.BillingAddress, "\n"), view.Invoice.CustomerEmail)...),
+	}
+	if view.Invoice.ShippingAddress != "" {
+		blocks = append(blocks, append([]string{"Ship to"}, nonEmpty(strings.Split(view.Invoice.ShippingAddress, "\n"))...))
+	}
+	tallest := 0
+	for i, block := range blocks {
+		for j, text := range block {
+			page.Text(left+float64(i)*170, y+float64(j)*12, size, j == 0, fitText(text, size, 160))
+		}
+		tallest = max(tallest, len(block))
+	}
+	y += float64(tallest)*12 + 20
+
+	header := func() {
+		for _, column := range columns {
+			if column.right {
+				page.TextRight(column.x, y, size, true, column.title)
+			} else {
+				page.Text(column.x, y, size, true, column.title)
+			}
+		}
+		y += 6
+		page.Line(left, y, right, y)
+		y += 14
+	}
+	header()
+
+	for _, line := range view.Lines {
+		if y > bottom {
+			page = doc.AddPage()
+			y = 60
+			header()
+		}
+		values := []string{fitText(line.Name, size, 175), fitText(line.SKU, size, 80), fmt.Sprint(line.Quantity), line.UnitPrice, line.Net, line.Tax, line.Total}
+		for i, column := range columns {
+			if column.right {
+				page.TextRight(column.x, y, size, false, values[i])
+			} else {
+				page.Text(column.x, y, size, false, values[i])
+			}
+		}
+		y += 16
+	}
+
+	if y > bottom-50 {
+		page = doc.AddPage()
+		y = 60
+	}
+	page.Line(left, y-8, right, y-8)
+	y += 8
+	for _, total := range [][2]string{{"Subtotal", view.Subtotal}, {view.TaxLabel, view.Tax}, {"Total", view.Total}} {
+		bold := total[0] == "Total"
+		page.TextRight(445, y, size, bold, total[0])
+		page.TextRight(right, y, size, bold, total[1])
+		y += 14
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+func prefixed(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// nonEmpty flattens lines and extra values, dropping empty ones
+func nonEmpty(lines []string, extra ...string) []string {
+	var out []string
+	for _, line := range append(lines, extra...) {
+		if strings.TrimSpace(line) != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// fitText shortens text with an ellipsis so it fits width
+func fitText(text string, size, width float64) string {
+	if pdf.TextWidth(text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.TextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"shopping-cart/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PublishedItems limits a query to items the public can see at now,
+// matching models.Item.EffectiveStatus
+func PublishedItems(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("(items.status = ? AND items.publish_at IS NULL) OR (items.status IN ? AND items.publish_at <= ?)",
+				models.ItemActive, []string{models.ItemActive, models.ItemDraft}, now).
+			Where("items.unpublish_at IS NULL OR items.unpublish_at > ?", now)
+	}
+}
+
+// ApplyItemSchedules stores the status of items whose publish or unpublish
+// time has passed, so the status column catches up with the schedule. It
+// returns the changed items, which need reindexing for search suggestions.
+func ApplyItemSchedules(db *gorm.DB, now time.Time) ([]uint, error) {
+	var changed []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var archived []uint
+		if err := tx.Model(&models.Item{}).
+			Where("status <> ? AND unpublish_at <= ?", models.ItemArchived, now).
+			Pluck("id", &archived).Error; err != nil {
+			return err
+		}
+		if len(archived) > 0 {
+			if err := tx.Model(&models.Item{}).Where("id IN ?", archived).Update("status", models.ItemArchived).Error; err != nil {
+				return err
+			}
+		}
+
+		var published []uint
+		if err := tx.Model(&models.Item{}).
+			Where("status = ? AND publish_at <= ?", models.ItemDraft, now).
+			Where("unpublish_at IS NULL OR unpublish_at > ?", now).
+			Pluck("id", &published).Error; err != nil {
+			return err
+		}
+		if len(published) > 0 {
+			if err := tx.Model(&models.Item{}).Where("id IN ?", published).Update("status", models.ItemActive).Error; err != nil {
+				return err
+			}
+		}
+
+		changed = append(archived, published...)
+		return nil
+	})
+	return changed, err
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"shopping-cart/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// repeatViewWindow is how long a viewer looking at the same item again is
+// not counted as a new view
+const repeatViewWindow = 30 * time.Minute
+
+// RecentlyViewedLimit is how many items are kept in a viewer's history
+func RecentlyViewedLimit() int {
+	return GetEnvInt("RECENTLY_VIEWED_LIMIT", 20)
+}
+
+// TrendingWindow is the period whose views make an item trending
+func TrendingWindow() time.Duration {
+	return time.Duration(GetEnvInt("TRENDING_WINDOW_DAYS", 7)) * 24 * time.Hour
+}
+
+// GuestViewTTL is how long a guest token and its history are kept after
+// the guest was last seen
+func GuestViewTTL() time.Duration {
+	return time.Duration(GetEnvInt("GUEST_VIEW_TTL_DAYS", 30)) * 24 * time.Hour
+}
+
+// UnusedGuestTokenTTL is how long a guest token that never recorded a view
+// is kept
+func UnusedGuestTokenTTL() time.Duration {
+	return time.Duration(GetEnvInt("GUEST_TOKEN_UNUSED_TTL_HOURS", 24)) * time.Hour
+}
+
+// IssueGuestToken creates a new guest token and returns it
+func IssueGuestToken(db *gorm.DB, now time.Time) (string, error) {
+	token, tokenHash, err := GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	if err := db.Create(&models.GuestToken{TokenHash: tokenHash, CreatedAt: now, LastSeenAt: now}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// TouchGuestToken marks a guest token as seen and reports whether it was
+// issued by IssueGuestToken and not yet expired
+func TouchGuestToken(db *gorm.DB, tokenHash string, now time.Time) (bool, error) {
+	result := db.Model(&models.GuestToken{}).Where("token_hash = ?", tokenHash).Update("last_seen_at", now)
+	return result.RowsAffected == 1, result.Error
+}
+
+// CleanupGuestViews removes guest tokens not seen within GuestViewTTL,
+// tokens never used within UnusedGuestTokenTTL, and the history of guests
+// whose token is gone. View counts are kept.
+func CleanupGuestViews(db *gorm.DB, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("last_seen_at < ?", now.Add(-GuestViewTTL())).Delete(&models.GuestToken{}).Error; err != nil {
+			return err
+		}
+		// TouchGuestToken moves last_seen_at on every recorded view
+		if err := tx.Where("last_seen_at <= created_at AND created_at < ?", now.Add(-UnusedGuestTokenTTL())).
+			Delete(&models.GuestToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id IS NULL AND guest_token_hash NOT IN (?)", tx.Model(&models.GuestToken{}).Select("token_hash")).
+			Delete(&models.ItemView{}).Error
+	})
+}
+
+// Viewer returns a query scope for the views of a user or, without one,
+// of a guest
+func Viewer(userID uint, guestTokenHash string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID != 0 {
+			return db.Where("item_views.user_id = ?", userID)
+		}
+		return db.Where("item_views.user_id IS NULL AND item_views.guest_token_hash = ?", guestTokenHash)
+	}
+}
+
+// RecordItemView moves the item to the top of the viewer's history,
+// dropping the oldest entries beyond RecentlyViewedLimit, and counts the
+// view towards the item's popularity
+func RecordItemView(db *gorm.DB, userID uint, guestTokenHash string, itemID uint, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		countView := true
+		var view models.ItemView
+		err := tx.Scopes(Viewer(userID, guestTokenHash)).Where("item_id = ?", itemID).First(&view).Error
+		switch {
+		case err == nil:
+			countView = now.Sub(view.ViewedAt) >= repeatViewWindow
+			if err := tx.Model(&view).Update("viewed_at", now).Error; err != nil {
+				return err
+			}
+		case err == gorm.ErrRecordNotFound:
+			view = models.ItemView{GuestTokenHash: guestTokenHash, ItemID: itemID, ViewedAt: now}
+			if userID != 0 {
+				view.UserID = &userID
+				view.GuestTokenHash = ""
+			}
+			if err := tx.Create(&view).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		var stale []uint
+		tx.Model(&models.ItemView{}).Scopes(Viewer(userID, guestTokenHash)).
+			Order("viewed_at DESC, id DESC").Offset(RecentlyViewedLimit()).Pluck("id", &stale)
+		if len(stale) > 0 {
+			if err := tx.Delete(&models.ItemView{}, stale).Error; err != nil {
+				return err
+			}
+		}
+
+		if !countView {
+			return nil
+		}
+		day := now.UTC().Truncate(24 * time.Hour)
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "item_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("item_view_counts.views + 1")}),
+		}).Create(&models.ItemViewCount{ItemID: itemID, Day: day, Views: 1}).Error
+	})
+}
+
+// TrendingItems orders an item query by views within TrendingWindow
+func TrendingItems(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		since := now.UTC().Add(-TrendingWindow()).Truncate(24 * time.Hour)
+		return db.
+			Joins("LEFT JOIN (SELECT item_id, SUM(views) AS recent_views FROM item_view_counts WHERE day >= ? GROUP BY item_id) AS trending ON trending.item_id = items.id", since).
+			Order("COALESCE(trending.recent_views, 0) DESC, items.id")
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"log"
+	"time"
+)
+
+// StartJob runs job every interval in the background, logging failures.
+// A zero interval disables the job.
+func StartJob(name string, interval time.Duration, job func(now time.Time) error) {
+	if interval <= 0 {
+		log.Printf("Background job %s disabled", name)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := job(now); err != nil {
+				log.Printf("Background job %s failed: %v", name, err)
+			}
+		}
+	}()
+}
@@ -32,28 +32,52 @@ func getJWTExpiryHours() int {
 	return hours
 }
 
+// TokenPurposeTwoFactor marks a short-lived token that only proves the
+// password step of a two-step login
+const TokenPurposeTwoFactor = "2fa_challenge"
+
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID       uint   `json:"user_id"`
+	TokenVersion uint   `json:"token_version"`
+	Purpose      string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint) (string, error) {
+// GenerateToken issues a JWT for the user. Tokens carrying an older
+// tokenVersion than the user's current one are rejected, which is how
+// sessions are revoked.
+func GenerateToken(userID uint, tokenVersion uint) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:       userID,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(getJWTExpiryHours()) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getJWTSecret())
+	return signToken(claims)
+}
+
+// GenerateChallengeToken issues the token returned by the password step of
+// a two-factor login. It is only accepted by the second login step.
+func GenerateChallengeToken(userID uint, tokenVersion uint) (string, error) {
+	ttl := GetEnvSeconds("TWO_FACTOR_CHALLENGE_TTL_SECONDS", 5*time.Minute)
+	claims := Claims{
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		Purpose:      TokenPurposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signToken(claims)
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return getJWTSecret(), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKeyFunc)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultJWTSecrets are placeholder secrets that must never be used in release mode
+var defaultJWTSecrets = map[string]bool{
+	"":                                     true,
+	"your-secret-key-change-in-production": true,
+	"your-super-secret-jwt-key-change-this-in-production": true,
+}
+
+// verificationKey is a public key accepted for tokens carrying its kid
+type verificationKey struct {
+	method    jwt.SigningMethod
+	publicKey crypto.PublicKey
+}
+
+// JWTKeySet holds the key used to sign new tokens and every key that is
+// still accepted for verification, so keys can be rotated without
+// invalidating tokens signed with the previous key.
+type JWTKeySet struct {
+	Algorithm     string
+	SigningKeyID  string
+	signingKey    crypto.PrivateKey
+	verifyingKeys map[string]verificationKey
+}
+
+var (
+	jwtKeys   *JWTKeySet
+	jwtKeysMu sync.Mutex
+)
+
+// getJWTKeys returns the configured key set, loading it on first use
+func getJWTKeys() (*JWTKeySet, error) {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+
+	if jwtKeys == nil {
+		keys, err := LoadJWTKeys()
+		if err != nil {
+			return nil, err
+		}
+		jwtKeys = keys
+	}
+	return jwtKeys, nil
+}
+
+// ResetJWTKeys drops the cached key set so it is reloaded on next use
+func ResetJWTKeys() {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	jwtKeys = nil
+}
+
+// LoadJWTKeys reads the key configuration from the environment.
+// JWT_ALGORITHM selects HS256 (default, using JWT_SECRET), RS256 or EdDSA.
+// For the asymmetric algorithms every <kid>.pem file in JWT_KEYS_DIR is an
+// active verification key and JWT_SIGNING_KEY_ID names the private key used
+// to sign new tokens.
+func LoadJWTKeys() (*JWTKeySet, error) {
+	keys := &JWTKeySet{
+		Algorithm:     GetEnv("JWT_ALGORITHM", jwt.SigningMethodHS256.Alg()),
+		verifyingKeys: make(map[string]verificationKey),
+	}
+
+	switch keys.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		return keys, nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", keys.Algorithm)
+	}
+
+	dir := os.Getenv("JWT_KEYS_DIR")
+	keys.SigningKeyID = os.Getenv("JWT_SIGNING_KEY_ID")
+	if dir == "" || keys.SigningKeyID == "" {
+		return nil, errors.New("JWT_KEYS_DIR and JWT_SIGNING_KEY_ID are required for " + keys.Algorithm)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, publicKey, method, err := parsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+		keys.verifyingKeys[kid] = verificationKey{method: method, publicKey: publicKey}
+
+		if kid == keys.SigningKeyID {
+			if privateKey == nil {
+				return nil, fmt.Errorf("signing key %s is not a private key", kid)
+			}
+			if method.Alg() != keys.Algorithm {
+				return nil, fmt.Errorf("signing key %s is not a %s key", kid, keys.Algorithm)
+			}
+			keys.signingKey = privateKey
+		}
+	}
+
+	if keys.signingKey == nil {
+		return nil, fmt.Errorf("signing key %s not found in %s", keys.SigningKeyID, dir)
+	}
+	return keys, nil
+}
+
+// parsePEMKey accepts an RSA or Ed25519 key in private or public PEM form
+func parsePEMKey(data []byte) (crypto.PrivateKey, crypto.PublicKey, jwt.SigningMethod, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return key, &key.PublicKey, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return nil, key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		privateKey := key.(ed25519.PrivateKey)
+		return privateKey, privateKey.Public(), jwt.SigningMethodEdDSA, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return nil, key, jwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, nil, errors.New("unsupported key format, expected an RSA or Ed25519 PEM key")
+}
+
+// signToken signs the claims with the active signing key
+func signToken(claims jwt.Claims) (string, error) {
+	keys, err := getJWTKeys()
+	if err != nil {
+		return "", err
+	}
+
+	if keys.Algorithm == jwt.SigningMethodHS256.Alg() {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getJWTSecret())
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(keys.Algorithm), claims)
+	token.Header["kid"] = keys.SigningKeyID
+	return token.SignedString(keys.signingKey)
+}
+
+// verificationKeyFunc resolves the key for a token from its kid header and
+// refuses tokens whose algorithm does not match that key
+func verificationKeyFunc(token *jwt.Token) (interface{}, error) {
+	keys, err := getJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if keys.Algorithm == jwt.SigningMethodHS256.Alg() {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return getJWTSecret(), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.verifyingKeys[kid]
+	if !ok {
+		return nil, errors.New("unknown key id")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.publicKey, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set. Nothing
+// is published for HS256 since the secret must stay private.
+func JWKS() (map[string]interface{}, error) {
+	keys, err := getJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	kids := make([]string, 0, len(keys.verifyingKeys))
+	for kid := range keys.verifyingKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := make([]map[string]interface{}, 0, len(kids))
+	for _, kid := range kids {
+		key := keys.verifyingKeys[kid]
+		switch publicKey := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, map[string]interface{}{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, map[string]interface{}{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": kid,
+				"x":   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return map[string]interface{}{"keys": jwks}, nil
+}
+
+// ValidateJWTConfig checks that the key configuration loads and, in release
+// mode, that HS256 is not running with a default or placeholder secret
+func ValidateJWTConfig(release bool) error {
+	keys, err := getJWTKeys()
+	if err != nil {
+		return err
+	}
+
+	if release && keys.Algorithm == jwt.SigningMethodHS256.Alg() && defaultJWTSecrets[os.Getenv("JWT_SECRET")] {
+		return errors.New("JWT_SECRET must be set to a non-default value in release mode")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"time"
+)
+
+// LoginLockoutDuration returns how long an account stays locked after the
+// given number of consecutive failed logins. Nothing is locked below
+// LOGIN_LOCKOUT_THRESHOLD; from there the lock doubles with each failure,
+// starting at LOGIN_LOCKOUT_BASE_SECONDS and capped at LOGIN_LOCKOUT_MAX_SECONDS.
+func LoginLockoutDuration(failedAttempts int) time.Duration {
+	threshold := GetEnvInt("LOGIN_LOCKOUT_THRESHOLD", 5)
+	if threshold <= 0 || failedAttempts < threshold {
+		return 0
+	}
+
+	base := GetEnvSeconds("LOGIN_LOCKOUT_BASE_SECONDS", 30*time.Second)
+	max := GetEnvSeconds("LOGIN_LOCKOUT_MAX_SECONDS", time.Hour)
+
+	lock := base
+	for i := threshold; i < failedAttempts && lock < max; i++ {
+		lock *= 2
+	}
+	if lock > max {
+		lock = max
+	}
+	return lock
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// MailMessage is a plain text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// Mail is the mailer used by the application, set up by InitMailer
+var Mail Mailer
+
+// InitMailer configures the mailer from MAILER ("log" or "file") and MAILER_DIR
+func InitMailer() {
+	switch GetEnv("MAILER", "log") {
+	case "file":
+		Mail = &FileMailer{Dir: GetEnv("MAILER_DIR", "mail")}
+	default:
+		Mail = &LogMailer{}
+	}
+}
+
+// SendMail sends a message through the configured mailer
+func SendMail(msg MailMessage) error {
+	if Mail == nil {
+		InitMailer()
+	}
+	return Mail.Send(msg)
+}
+
+// LogMailer writes messages to the application log
+type LogMailer struct{}
+
+func (m *LogMailer) Send(msg MailMessage) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own file in Dir
+type FileMailer struct {
+	Dir string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *FileMailer) Send(msg MailMessage) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"database/sql"
+	"math"
+	"shopping-cart/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartCartSession opens a cart session unless the cart already has one
+func StartCartSession(tx *gorm.DB, cart models.Cart, now time.Time) error {
+	var open int64
+	if err := tx.Model(&models.CartSession{}).Where("cart_id = ? AND order_id IS NULL", cart.ID).Count(&open).Error; err != nil {
+		return err
+	}
+	if open > 0 {
+		return nil
+	}
+	return tx.Create(&models.CartSession{CartID: cart.ID, UserID: cart.UserID, StartedAt: now}).Error
+}
+
+// CloseCartSession marks the cart's open session as converted to the order
+func CloseCartSession(tx *gorm.DB, cartID, orderID uint) error {
+	return tx.Model(&models.CartSession{}).Where("cart_id = ? AND order_id IS NULL", cartID).Update("order_id", orderID).Error
+}
+
+// RollupMetrics rebuilds the hourly and daily metrics from the start of
+// the day METRICS_ROLLUP_LOOKBACK_DAYS ago up to now. Orders get paid and
+// cart sessions convert after the period they belong to, so recent periods
+// are rebuilt on every run. The first run backfills all history.
+func RollupMetrics(db *gorm.DB, now time.Time) error {
+	start := PeriodStart(now.AddDate(0, 0, -GetEnvInt("METRICS_ROLLUP_LOOKBACK_DAYS", 7)), PeriodDay)
+
+	var rolledUp int64
+	if err := db.Model(&models.DailyMetric{}).Count(&rolledUp).Error; err != nil {
+		return err
+	}
+	if rolledUp == 0 {
+		var user models.User
+		if err := db.Unscoped().Order("created_at").First(&user).Error; err == nil && user.CreatedAt.Before(start) {
+			start = PeriodStart(user.CreatedAt, PeriodDay)
+		}
+	}
+	end := PeriodStart(now, PeriodHour).Add(time.Hour)
+
+	hours := map[time.Time]*models.MetricCounts{}
+	counts := func(at time.Time) *models.MetricCounts {
+		hour := PeriodStart(at, PeriodHour)
+		if hours[hour] == nil {
+			hours[hour] = &models.MetricCounts{}
+		}
+		return hours[hour]
+	}
+
+	err := eachRow(db.Model(&models.Order{}).Select("created_at, total, status").
+		Where("created_at >= ? AND created_at < ?", start, end), func(rows *sql.Rows) error {
+		var createdAt time.Time
+		var total float64
+		var status string
+		if err := rows.Scan(&createdAt, &total, &status); err != nil {
+			return err
+		}
+		hour := counts(createdAt)
+		hour.Orders++
+		if IsInvoiceStatus(status) {
+			hour.Revenue += total
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deleted accounts still count as signups
+	err = eachRow(db.Unscoped().Model(&models.User{}).Select("created_at").
+		Where("created_at >= ? AND created_at < ?", start, end), func(rows *sql.Rows) error {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return err
+		}
+		counts(createdAt).Signups++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = eachRow(db.Model(&models.CartSession{}).Select("started_at, order_id").
+		Where("started_at >= ? AND started_at < ?", start, end), func(rows *sql.Rows) error {
+		var startedAt time.Time
+		var orderID *uint
+		if err := rows.Scan(&startedAt, &orderID); err != nil {
+			return err
+		}
+		hour := counts(startedAt)
+		hour.CartsCreated++
+		if orderID != nil {
+			hour.CartsConverted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	hourly := make([]models.HourlyMetric, 0, len(hours))
+	days := map[time.Time]*models.MetricCounts{}
+	for hour, hourCounts := range hours {
+		hourCounts.Revenue = roundMoney(hourCounts.Revenue)
+		hourly = append(hourly, models.HourlyMetric{Hour: hour, MetricCounts: *hourCounts})
+
+		day := PeriodStart(hour, PeriodDay)
+		if days[day] == nil {
+			days[day] = &models.MetricCounts{}
+		}
+		addMetricCounts(days[day], *hourCounts)
+	}
+	daily := make([]models.DailyMetric, 0, len(days))
+	for day, dayCounts := range days {
+		dayCounts.Revenue = roundMoney(dayCounts.Revenue)
+		daily = append(daily, models.DailyMetric{Day: day, MetricCounts: *dayCounts})
+	}
+	sort.Slice(hourly, func(i, j int) bool { return hourly[i].Hour.Before(hourly[j].Hour) })
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Day.Before(daily[j].Day) })
+
+	// Swap in the new rollups at once so the dashboard never sees a gap
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("hour >= ?", start).Delete(&models.HourlyMetric{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day >= ?", start).Delete(&models.DailyMetric{}).Error; err != nil {
+			return err
+		}
+		if len(hourly) > 0 {
+			if err := tx.CreateInBatches(hourly, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(daily) > 0 {
+			return tx.CreateInBatches(daily, 500).Error
+		}
+		return nil
+	})
+}
+
+func eachRow(query *gorm.DB, scan func(rows *sql.Rows) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func addMetricCounts(total *models.MetricCounts, counts models.MetricCounts) {
+	total.Orders += counts.Orders
+	total.Revenue += counts.Revenue
+	total.Signups += counts.Signups
+	total.CartsCreated += counts.CartsCreated
+	total.CartsConverted += counts.CartsConverted
+}
+
+// MetricPoint is the dashboard figures of one period. ConversionRate is
+// the share of cart sessions started in the period that became orders.
+type MetricPoint struct {
+	PeriodStart time.Time `json:"period_start"`
+	models.MetricCounts
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+func newMetricPoint(start time.Time, counts models.MetricCounts) MetricPoint {
+	point := MetricPoint{PeriodStart: start, MetricCounts: counts}
+	point.Revenue = roundMoney(point.Revenue)
+	if point.CartsCreated > 0 {
+		point.ConversionRate = math.Round(float64(point.CartsConverted)/float64(point.CartsCreated)*10000) / 10000
+	}
+	return point
+}
+
+// MetricsSeries reads the rollups for the periods overlapping [from, to)
+// at the given granularity, with empty periods as zeros, and their totals
+func MetricsSeries(db *gorm.DB, from, to time.Time, granularity string) ([]MetricPoint, MetricPoint, error) {
+	first := PeriodStart(from, granularity)
+	buckets := map[time.Time]*models.MetricCounts{}
+	add := func(at time.Time, counts models.MetricCounts) {
+		start := PeriodStart(at, granularity)
+		if buckets[start] == nil {
+			buckets[start] = &models.MetricCounts{}
+		}
+		addMetricCounts(buckets[start], counts)
+	}
+
+	if granularity == PeriodHour {
+		var rows []models.HourlyMetric
+		if err := db.Where("hour >= ? AND hour < ?", first, to.UTC()).Find(&rows).Error; err != nil {
+			return nil, MetricPoint{}, err
+		}
+		for _, row := range rows {
+			add(row.Hour, row.MetricCounts)
+		}
+	} else {
+		var rows []models.DailyMetric
+		if err := db.Where("day >= ? AND day < ?", PeriodStart(first, PeriodDay), to.UTC()).Find(&rows).Error; err != nil {
+			return nil, MetricPoint{}, err
+		}
+		for _, row := range rows {
+			add(row.Day, row.MetricCounts)
+		}
+	}
+
+	points := []MetricPoint{}
+	var totals models.MetricCounts
+	for start := first; start.Before(to); start = NextPeriod(start, granularity) {
+		var counts models.MetricCounts
+		if bucket := buckets[start]; bucket != nil {
+			counts = *bucket
+		}
+		addMetricCounts(&totals, counts)
+		points = append(points, newMetricPoint(start, counts))
+	}
+	return points, newMetricPoint(first, totals), nil
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrOIDCNotConfigured is returned when no OpenID Connect provider is set up
+var ErrOIDCNotConfigured = errors.New("OIDC provider is not configured")
+
+// oidcHTTPClient is used for discovery, token and JWKS requests
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCProvider is an OpenID Connect provider resolved through discovery
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims are the ID token claims used to find or create a user
+type OIDCClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+var (
+	oidcProviders   = map[string]*OIDCProvider{}
+	oidcProvidersMu sync.Mutex
+)
+
+// GetOIDCProvider returns the provider configured by OIDC_ISSUER,
+// OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL and OIDC_SCOPES.
+// Discovery results are cached per issuer.
+func GetOIDCProvider() (*OIDCProvider, error) {
+	issuer := strings.TrimSuffix(GetEnv("OIDC_ISSUER", ""), "/")
+	clientID := GetEnv("OIDC_CLIENT_ID", "")
+	if issuer == "" || clientID == "" {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+
+	discovered, ok := oidcProviders[issuer]
+	if !ok {
+		resp, err := oidcHTTPClient.Get(issuer + "/.well-known/openid-configuration")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OIDC discovery failed with status %d", resp.StatusCode)
+		}
+
+		discovered = &OIDCProvider{}
+		if err := json.NewDecoder(resp.Body).Decode(discovered); err != nil {
+			return nil, err
+		}
+		oidcProviders[issuer] = discovered
+	}
+
+	provider := *discovered
+	provider.Name = GetEnv("OIDC_PROVIDER_NAME", "oidc")
+	provider.Issuer = issuer
+	provider.ClientID = clientID
+	provider.ClientSecret = GetEnv("OIDC_CLIENT_SECRET", "")
+	provider.RedirectURL = GetEnv("OIDC_REDIRECT_URL", "http://localhost:8080/auth/oidc/callback")
+	provider.Scopes = strings.Fields(GetEnv("OIDC_SCOPES", "openid email profile"))
+	return &provider, nil
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier and its S256 challenge
+func NewPKCEVerifier() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// AuthCodeURL builds the authorization request URL
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange trades an authorization code for the raw ID token
+func (p *OIDCProvider) Exchange(code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	resp, err := oidcHTTPClient.PostForm(p.TokenEndpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response did not include an id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token signature against the provider's JWKS
+// along with its issuer, audience, expiry and nonce
+func (p *OIDCProvider) VerifyIDToken(rawToken, nonce string) (*OIDCClaims, error) {
+	keys, err := p.fetchJWKS()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &OIDCClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok && kid == "" && len(keys) == 1 {
+			for _, only := range keys {
+				key, ok = only, true
+			}
+		}
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return claims, nil
+}
+
+// fetchJWKS downloads the provider's signing keys
+func (p *OIDCProvider) fetchJWKS() (map[string]interface{}, error) {
+	resp, err := oidcHTTPClient.Get(p.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed with status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, key := range set.Keys {
+		switch {
+		case key.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[key.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case key.Kty == "OKP" && key.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[key.Kid] = ed25519.PublicKey(x)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// ValidatePassword checks a password against the configured strength rules:
+// PASSWORD_MIN_LENGTH plus PASSWORD_REQUIRE_LETTER, PASSWORD_REQUIRE_UPPER,
+// PASSWORD_REQUIRE_DIGIT and PASSWORD_REQUIRE_SYMBOL.
+func ValidatePassword(password string) error {
+	minLength := GetEnvInt("PASSWORD_MIN_LENGTH", 8)
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("password must be at least %d characters long", minLength)
+	}
+
+	var hasLetter, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+			hasLetter = true
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if GetEnvBool("PASSWORD_REQUIRE_LETTER", true) && !hasLetter {
+		return errors.New("password must contain a letter")
+	}
+	if GetEnvBool("PASSWORD_REQUIRE_UPPER", false) && !hasUpper {
+		return errors.New("password must contain an uppercase letter")
+	}
+	if GetEnvBool("PASSWORD_REQUIRE_DIGIT", true) && !hasDigit {
+		return errors.New("password must contain a digit")
+	}
+	if GetEnvBool("PASSWORD_REQUIRE_SYMBOL", false) && !hasSymbol {
+		return errors.New("password must contain a symbol")
+	}
+	return nil
+}
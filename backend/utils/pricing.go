@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"shopping-cart/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordPriceChange closes the item's current price period and opens a new
+// one. previous is nil for an item's first price.
+func RecordPriceChange(tx *gorm.DB, itemID uint, previous *float64, price float64, reason string, changedByID *uint, now time.Time) error {
+	if err := tx.Model(&models.PriceHistory{}).
+		Where("item_id = ? AND effective_to IS NULL", itemID).
+		Update("effective_to", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.PriceHistory{
+		ItemID:        itemID,
+		Price:         price,
+		PreviousPrice: previous,
+		Reason:        reason,
+		ChangedByID:   changedByID,
+		EffectiveFrom: now,
+	}).Error
+}
+
+// ErrPriceScheduleChanged is returned when a schedule was started, ended or
+// cancelled by someone else first
+var ErrPriceScheduleChanged = errors.New("price schedule has already changed")
+
+// ApplyScheduledPrices starts scheduled price changes and sales that are due
+// and reverts sales that have ended. Each schedule is claimed in its own
+// transaction, so concurrent runs never apply one twice.
+func ApplyScheduledPrices(db *gorm.DB, now time.Time) error {
+	// End sales first so a sale can hand over to one starting right after it
+	var ending []models.ScheduledPrice
+	if err := db.Where("status = ? AND ends_at <= ?", models.PriceScheduleActive, now).Find(&ending).Error; err != nil {
+		return err
+	}
+	for _, schedule := range ending {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return endSale(tx, schedule, models.PriceScheduleCompleted, now)
+		}); err != nil && !errors.Is(err, ErrPriceScheduleChanged) {
+			return err
+		}
+	}
+
+	var due []models.ScheduledPrice
+	if err := db.Where("status = ? AND starts_at <= ?", models.PriceSchedulePending, now).Order("starts_at").Find(&due).Error; err != nil {
+		return err
+	}
+	for _, schedule := range due {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return startScheduledPrice(tx, schedule, now)
+		}); err != nil && !errors.Is(err, ErrPriceScheduleChanged) {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimSchedule moves a schedule from one status to another, failing with
+// ErrPriceScheduleChanged if it is no longer in the first
+func claimSchedule(tx *gorm.DB, schedule models.ScheduledPrice, from, to string) error {
+	result := tx.Model(&models.ScheduledPrice{}).
+		Where("id = ? AND status = ?", schedule.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPriceScheduleChanged
+	}
+	return nil
+}
+
+func startScheduledPrice(tx *gorm.DB, schedule models.ScheduledPrice, now time.Time) error {
+	// A sale whose window passed while the worker was not running is skipped
+	if schedule.EndsAt != nil && !now.Before(*schedule.EndsAt) {
+		return claimSchedule(tx, schedule, models.PriceSchedulePending, models.PriceScheduleCompleted)
+	}
+
+	reason := models.PriceReasonScheduled
+	status := models.PriceScheduleCompleted
+	if schedule.EndsAt != nil {
+		reason = models.PriceReasonSaleStart
+		status = models.PriceScheduleActive
+	}
+	if err := claimSchedule(tx, schedule, models.PriceSchedulePending, status); err != nil {
+		return err
+	}
+
+	// The item is read after the claim so the original price is never a
+	// price this schedule set
+	var item models.Item
+	if err := tx.Unscoped().First(&item, schedule.ItemID).Error; err != nil {
+		return tx.Model(&schedule).Update("status", models.PriceScheduleCancelled).Error
+	}
+
+	if err := setItemPrice(tx, item, schedule.Price, reason, now); err != nil {
+		return err
+	}
+	return tx.Model(&schedule).Update("original_price", item.Price).Error
+}
+
+func endSale(tx *gorm.DB, schedule models.ScheduledPrice, status string, now time.Time) error {
+	if err := claimSchedule(tx, schedule, models.PriceScheduleActive, status); err != nil {
+		return err
+	}
+
+	var item models.Item
+	err := tx.Unscoped().First(&item, schedule.ItemID).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	// Leave prices alone that an admin changed during the sale
+	if err == nil && schedule.OriginalPrice != nil && item.Price == schedule.Price {
+		return setItemPrice(tx, item, *schedule.OriginalPrice, models.PriceReasonSaleEnd, now)
+	}
+	return nil
+}
+
+// CancelScheduledPrice cancels a pending change, or reverts an active sale
+// immediately. It returns ErrPriceScheduleChanged if the schedule started
+// or finished in the meantime.
+func CancelScheduledPrice(tx *gorm.DB, schedule models.ScheduledPrice, now time.Time) error {
+	if schedule.Status == models.PriceScheduleActive {
+		return endSale(tx, schedule, models.PriceScheduleCancelled, now)
+	}
+	return claimSchedule(tx, schedule, models.PriceSchedulePending, models.PriceScheduleCancelled)
+}
+
+func setItemPrice(tx *gorm.DB, item models.Item, price float64, reason string, now time.Time) error {
+	if err := tx.Unscoped().Model(&models.Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"price":   price,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	previous := item.Price
+	return RecordPriceChange(tx, item.ID, &previous, price, reason, nil, now)
+}
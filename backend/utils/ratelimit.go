@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucketIdleTimeout is how long an unused bucket is kept before being dropped
+const bucketIdleTimeout = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is an in-memory token bucket limiter keyed by an arbitrary
+// string such as a client IP or user ID.
+type RateLimiter struct {
+	rate      float64 // tokens added per second
+	burst     float64 // bucket capacity
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter refilling at rate tokens per second up to burst
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// NewRateLimiterFromEnv builds a limiter from <prefix>_RPS and <prefix>_BURST
+func NewRateLimiterFromEnv(prefix string, defaultRate float64, defaultBurst int) *RateLimiter {
+	return NewRateLimiter(
+		GetEnvFloat(prefix+"_RPS", defaultRate),
+		GetEnvInt(prefix+"_BURST", defaultBurst),
+	)
+}
+
+// Allow takes a token for key. When the bucket is empty it returns false and
+// how long the caller should wait before retrying.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last request
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.lastSeen).Seconds()*l.rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	if l.rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops idle buckets so the map does not grow without bound
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > bucketIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RetryAfterSeconds formats a wait as a Retry-After value in whole seconds
+func RetryAfterSeconds(wait time.Duration) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
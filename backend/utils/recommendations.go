@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"shopping-cart/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sameCategoryScore is added for related items in the same category, so a
+// single co-purchase outranks a category match and ties break on category
+const sameCategoryScore = 0.5
+
+// RecommendationsPerItem is how many related items are kept for each item
+func RecommendationsPerItem() int {
+	return GetEnvInt("RECOMMENDATIONS_PER_ITEM", 20)
+}
+
+// RebuildRecommendations recomputes co-purchase counts from order lines
+// and replaces the item_recommendations table with the top related items
+// of every item. Cancelled orders are ignored.
+func RebuildRecommendations(db *gorm.DB, now time.Time) error {
+	type pairCount struct {
+		ItemID        uint
+		RelatedItemID uint
+		CoPurchases   int
+	}
+	var pairs []pairCount
+	if err := db.Table("order_items AS a").
+		Select("a.item_id, b.item_id AS related_item_id, COUNT(DISTINCT a.order_id) AS co_purchases").
+		Joins("JOIN order_items AS b ON b.order_id = a.order_id AND b.item_id <> a.item_id").
+		Joins("JOIN orders ON orders.id = a.order_id AND orders.deleted_at IS NULL").
+		Where("orders.status <> ?", models.OrderCancelled).
+		Group("a.item_id, b.item_id").
+		Scan(&pairs).Error; err != nil {
+		return err
+	}
+
+	type itemInfo struct {
+		ID         uint
+		CategoryID *uint
+	}
+	var items []itemInfo
+	if err := db.Model(&models.Item{}).Select("id, category_id").
+		Order("rating DESC, reviews DESC, id").Scan(&items).Error; err != nil {
+		return err
+	}
+
+	limit := RecommendationsPerItem()
+	categoryOf := map[uint]*uint{}
+	byCategory := map[uint][]uint{}
+	for _, item := range items {
+		categoryOf[item.ID] = item.CategoryID
+		if item.CategoryID != nil {
+			byCategory[*item.CategoryID] = append(byCategory[*item.CategoryID], item.ID)
+		}
+	}
+
+	candidates := map[uint]map[uint]*models.ItemRecommendation{}
+	candidate := func(itemID, relatedID uint) *models.ItemRecommendation {
+		if candidates[itemID] == nil {
+			candidates[itemID] = map[uint]*models.ItemRecommendation{}
+		}
+		rec := candidates[itemID][relatedID]
+		if rec == nil {
+			rec = &models.ItemRecommendation{ItemID: itemID, RelatedItemID: relatedID, ComputedAt: now}
+			candidates[itemID][relatedID] = rec
+		}
+		return rec
+	}
+
+	for _, pair := range pairs {
+		// Lines of deleted items have no entry and are skipped
+		if _, ok := categoryOf[pair.ItemID]; !ok {
+			continue
+		}
+		if _, ok := categoryOf[pair.RelatedItemID]; !ok {
+			continue
+		}
+		candidate(pair.ItemID, pair.RelatedItemID).CoPurchases = pair.CoPurchases
+	}
+	for _, item := range items {
+		if item.CategoryID == nil {
+			continue
+		}
+		// Items are sorted by rating, so the best rated siblings come first
+		added := 0
+		for _, siblingID := range byCategory[*item.CategoryID] {
+			if siblingID == item.ID {
+				continue
+			}
+			if added >= limit {
+				break
+			}
+			candidate(item.ID, siblingID)
+			added++
+		}
+	}
+
+	var rows []models.ItemRecommendation
+	for itemID, related := range candidates {
+		list := make([]*models.ItemRecommendation, 0, len(related))
+		for relatedID, rec := range related {
+			cat, relatedCat := categoryOf[itemID], categoryOf[relatedID]
+			rec.SameCategory = cat != nil && relatedCat != nil && *cat == *relatedCat
+			rec.Score = float64(rec.CoPurchases)
+			if rec.SameCategory {
+				rec.Score += sameCategoryScore
+			}
+			list = append(list, rec)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].RelatedItemID < list[j].RelatedItemID
+		})
+		if len(list) > limit {
+			list = list[:limit]
+		}
+		for _, rec := range list {
+			rows = append(rows, *rec)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ItemRecommendation{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
@@ -0,0 +1,362 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"shopping-cart/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report periods
+const (
+	PeriodHour  = "hour"
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Order export formats
+const (
+	OrderExportCSV   = "csv"
+	OrderExportJSONL = "jsonl"
+)
+
+var ErrUnknownOrderExportFormat = errors.New("format must be csv or jsonl")
+
+// ErrTooManyPeriods is returned when a report would have more points than
+// allowed
+var ErrTooManyPeriods = errors.New("range covers too many periods")
+
+// OrderFilter narrows orders for reports. From is inclusive and To is
+// exclusive. No statuses means every status.
+type OrderFilter struct {
+	From     *time.Time
+	To       *time.Time
+	Statuses []string
+	UserID   uint
+}
+
+// Apply adds the filter to a query that includes the orders table
+func (f OrderFilter) Apply(query *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		query = query.Where("orders.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("orders.created_at < ?", *f.To)
+	}
+	if len(f.Statuses) > 0 {
+		query = query.Where("orders.status IN ?", f.Statuses)
+	}
+	if f.UserID != 0 {
+		query = query.Where("orders.user_id = ?", f.UserID)
+	}
+	return query
+}
+
+// PeriodStart truncates t to the start of its hour, day, ISO week
+// (starting on Monday) or month in UTC
+func PeriodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodHour:
+		return t.Truncate(time.Hour)
+	case PeriodWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// NextPeriod returns the start of the period after the one starting at start
+func NextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case PeriodHour:
+		return start.Add(time.Hour)
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// OrderSummary totals a set of orders. Revenue is what customers paid,
+// tax included; Tax comes from the orders' invoices.
+type OrderSummary struct {
+	Orders            int64   `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	Tax               float64 `json:"tax"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+func (s *OrderSummary) add(total, tax float64) {
+	s.Orders++
+	s.Revenue += total
+	s.Tax += tax
+}
+
+func (s *OrderSummary) finish() {
+	s.Revenue = roundMoney(s.Revenue)
+	s.Tax = roundMoney(s.Tax)
+	if s.Orders > 0 {
+		s.AverageOrderValue = roundMoney(s.Revenue / float64(s.Orders))
+	}
+}
+
+// RevenuePoint is the summary of the orders placed in one period
+type RevenuePoint struct {
+	PeriodStart time.Time `json:"period_start"`
+	OrderSummary
+}
+
+// RevenueByPeriod totals orders per period along with the overall summary.
+// Periods without orders are included as zeros, from the filter's From (or
+// the first order) up to its To (or the last order). More than maxPoints
+// periods fail with ErrTooManyPeriods.
+func RevenueByPeriod(db *gorm.DB, filter OrderFilter, period string, maxPoints int) ([]RevenuePoint, OrderSummary, error) {
+	var summary OrderSummary
+	rows, err := filter.Apply(db.Model(&models.Order{})).
+		Select("orders.created_at, orders.total, COALESCE(invoices.tax_amount, 0)").
+		Joins("LEFT JOIN invoices ON invoices.order_id = orders.id").
+		Order("orders.created_at").
+		Rows()
+	if err != nil {
+		return nil, summary, err
+	}
+	defer rows.Close()
+
+	byPeriod := map[time.Time]*OrderSummary{}
+	var first, last time.Time
+	for rows.Next() {
+		var createdAt time.Time
+		var total, tax float64
+		if err := rows.Scan(&createdAt, &total, &tax); err != nil {
+			return nil, summary, err
+		}
+		start := PeriodStart(createdAt, period)
+		if byPeriod[start] == nil {
+			byPeriod[start] = &OrderSummary{}
+		}
+		byPeriod[start].add(total, tax)
+		summary.add(total, tax)
+
+		if first.IsZero() {
+			first = start
+		}
+		last = start
+	}
+	if err := rows.Err(); err != nil {
+		return nil, summary, err
+	}
+	summary.finish()
+
+	if filter.From != nil {
+		first = PeriodStart(*filter.From, period)
+	}
+	if filter.To != nil {
+		last = PeriodStart(filter.To.Add(-time.Nanosecond), period)
+	}
+	points := []RevenuePoint{}
+	if first.IsZero() {
+		return points, summary, nil
+	}
+	for start := first; !start.After(last); start = NextPeriod(start, period) {
+		if len(points) == maxPoints {
+			return nil, summary, ErrTooManyPeriods
+		}
+		point := RevenuePoint{PeriodStart: start}
+		if totals := byPeriod[start]; totals != nil {
+			point.OrderSummary = *totals
+		}
+		point.finish()
+		points = append(points, point)
+	}
+	return points, summary, nil
+}
+
+// TopItem is an item ranked by the revenue of its order lines
+type TopItem struct {
+	ItemID   uint    `json:"item_id"`
+	Name     string  `json:"name"`
+	Quantity int64   `json:"quantity"`
+	Orders   int64   `json:"orders"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// TopItems ranks items by line revenue in the filtered orders. Names are
+// taken from the most recent order line.
+func TopItems(db *gorm.DB, filter OrderFilter, limit int) ([]TopItem, error) {
+	var items []TopItem
+	err := filter.Apply(db.Model(&models.OrderItem{})).
+		Select("order_items.item_id, " +
+			"(SELECT latest.name FROM order_items latest WHERE latest.item_id = order_items.item_id ORDER BY latest.id DESC LIMIT 1) AS name, " +
+			"SUM(order_items.quantity) AS quantity, COUNT(DISTINCT order_items.order_id) AS orders, " +
+			"SUM(order_items.price * order_items.quantity) AS revenue").
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Group("order_items.item_id").
+		Order("revenue DESC, quantity DESC, order_items.item_id").
+		Limit(limit).
+		Scan(&items).Error
+	for i := range items {
+		items[i].Revenue = roundMoney(items[i].Revenue)
+	}
+	return items, err
+}
+
+// TopCategory is a category ranked by the revenue of its items' order lines
+type TopCategory struct {
+	CategoryID *uint   `json:"category_id"`
+	Name       string  `json:"name"`
+	Quantity   int64   `json:"quantity"`
+	Orders     int64   `json:"orders"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// TopCategories ranks categories by line revenue in the filtered orders,
+// using each item's current category. Items without a linked category are
+// grouped by their category name, which may be empty.
+func TopCategories(db *gorm.DB, filter OrderFilter, limit int) ([]TopCategory, error) {
+	var categories []TopCategory
+	err := filter.Apply(db.Model(&models.OrderItem{})).
+		Select("categories.id AS category_id, COALESCE(categories.name, items.category, '') AS name, " +
+			"SUM(order_items.quantity) AS quantity, COUNT(DISTINCT order_items.order_id) AS orders, " +
+			"SUM(order_items.price * order_items.quantity) AS revenue").
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Joins("LEFT JOIN items ON items.id = order_items.item_id").
+		Joins("LEFT JOIN categories ON categories.id = items.category_id").
+		Group("categories.id, COALESCE(categories.name, items.category, '')").
+		Order("revenue DESC, quantity DESC, name").
+		Limit(limit).
+		Scan(&categories).Error
+	for i := range categories {
+		categories[i].Revenue = roundMoney(categories[i].Revenue)
+	}
+	return categories, err
+}
+
+// OrderExportRow is one order line in an export. Order columns repeat on
+// every line of the order.
+type OrderExportRow struct {
+	OrderID       uint      `json:"order_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Status        string    `json:"status"`
+	UserID        uint      `json:"user_id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	InvoiceNumber string    `json:"invoice_number"`
+	OrderTotal    float64   `json:"order_total"`
+	ItemID        uint      `json:"item_id"`
+	SKU           string    `json:"sku"`
+	Name          string    `json:"name"`
+	Quantity      int       `json:"quantity"`
+	UnitPrice     float64   `json:"unit_price"`
+	LineTotal     float64   `json:"line_total"`
+}
+
+var orderExportColumns = []string{
+	"order_id", "created_at", "status", "user_id", "username", "email", "invoice_number",
+	"order_total", "item_id", "sku", "name", "quantity", "unit_price", "line_total",
+}
+
+// csvText guards a text cell against formula injection, since spreadsheets
+// evaluate cells starting with one of these characters
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ExportOrders streams the filtered orders to w, one row per order line,
+// in batches so large exports are never held in memory at once. Text cells
+// of CSV exports are escaped with csvText.
+func ExportOrders(db *gorm.DB, w io.Writer, format string, filter OrderFilter) error {
+	var writeRow func(row OrderExportRow) error
+	var flush func() error
+
+	switch format {
+	case OrderExportCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(orderExportColumns); err != nil {
+			return err
+		}
+		money := func(amount float64) string { return strconv.FormatFloat(amount, 'f', 2, 64) }
+		writeRow = func(row OrderExportRow) error {
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(row.OrderID), 10),
+				row.CreatedAt.UTC().Format(time.RFC3339),
+				csvText(row.Status),
+				strconv.FormatUint(uint64(row.UserID), 10),
+				csvText(row.Username),
+				csvText(row.Email),
+				csvText(row.InvoiceNumber),
+				money(row.OrderTotal),
+				strconv.FormatUint(uint64(row.ItemID), 10),
+				csvText(row.SKU),
+				csvText(row.Name),
+				strconv.Itoa(row.Quantity),
+				money(row.UnitPrice),
+				money(row.LineTotal),
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case OrderExportJSONL:
+		encoder := json.NewEncoder(w)
+		writeRow = func(row OrderExportRow) error { return encoder.Encode(row) }
+		flush = func() error { return nil }
+	default:
+		return ErrUnknownOrderExportFormat
+	}
+
+	var orders []models.Order
+	query := filter.Apply(db.Model(&models.Order{})).
+		Preload("User", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Invoice").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("orders.id")
+	result := query.FindInBatches(&orders, 500, func(tx *gorm.DB, batch int) error {
+		for _, order := range orders {
+			row := OrderExportRow{
+				OrderID:    order.ID,
+				CreatedAt:  order.CreatedAt,
+				Status:     order.Status,
+				UserID:     order.UserID,
+				Username:   order.User.Username,
+				Email:      order.User.Email,
+				OrderTotal: order.Total,
+			}
+			if order.Invoice != nil {
+				row.InvoiceNumber = order.Invoice.Number
+			}
+			for _, line := range order.Items {
+				row.ItemID = line.ItemID
+				row.SKU = line.SKU
+				row.Name = line.Name
+				row.Quantity = line.Quantity
+				row.UnitPrice = line.Price
+				row.LineTotal = roundMoney(line.Price * float64(line.Quantity))
+				if err := writeRow(row); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	return flush()
+}
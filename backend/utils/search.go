@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"shopping-cart/models"
+	"shopping-cart/search"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SearchIndex holds every item for GET /search. It lives in memory, so it
+// is rebuilt at startup and kept current as items change.
+var SearchIndex = search.NewIndex(
+	search.Field{Name: "name", Weight: 3},
+	search.Field{Name: "category", Weight: 2},
+	search.Field{Name: "description", Weight: 1},
+)
+
+// itemDocument is the indexed form of an item. Only published items feed
+// suggestions so drafts don't leak through autocomplete. The visibility
+// follows PublishedItems, so public searches honour schedules right away.
+func itemDocument(item models.Item, now time.Time) search.Document {
+	return search.Document{
+		ID: item.ID,
+		Fields: map[string]string{
+			"name":        item.Name,
+			"category":    item.Category,
+			"description": item.Description,
+		},
+		Suggest: item.EffectiveStatus(now) == models.ItemActive,
+		Visibility: search.Visibility{
+			Hidden: item.Status != models.ItemActive && (item.Status != models.ItemDraft || item.PublishAt == nil),
+			From:   item.PublishAt,
+			Until:  item.UnpublishAt,
+		},
+	}
+}
+
+// BuildSearchIndex replaces the index with all items in the database
+func BuildSearchIndex(db *gorm.DB) error {
+	now := time.Now()
+	var docs []search.Document
+	var items []models.Item
+	err := db.FindInBatches(&items, 500, func(tx *gorm.DB, batch int) error {
+		for _, item := range items {
+			docs = append(docs, itemDocument(item, now))
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	SearchIndex.Replace(docs)
+	return nil
+}
+
+// IndexItems refreshes the given items in the index, removing the ones
+// that no longer exist
+func IndexItems(db *gorm.DB, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var items []models.Item
+	if err := db.Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	found := map[uint]bool{}
+	for _, item := range items {
+		found[item.ID] = true
+		SearchIndex.Add(itemDocument(item, now))
+	}
+	for _, id := range ids {
+		if !found[id] {
+			SearchIndex.Remove(id)
+		}
+	}
+	return nil
+}
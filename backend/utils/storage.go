@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidStorageKey is returned for keys that would escape the storage root
+var ErrInvalidStorageKey = errors.New("invalid storage key")
+
+// Storage keeps uploaded files under slash separated keys
+type Storage interface {
+	Save(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// URL returns the public URL the file is served from
+	URL(key string) string
+}
+
+// Store is the storage used by the application, set up by InitStorage
+var Store Storage
+
+// InitStorage configures the storage from STORAGE_DRIVER ("local"),
+// STORAGE_DIR and STORAGE_BASE_URL
+func InitStorage() {
+	switch GetEnv("STORAGE_DRIVER", "local") {
+	default:
+		Store = &LocalStorage{
+			Dir:     GetEnv("STORAGE_DIR", "uploads"),
+			BaseURL: GetEnv("STORAGE_BASE_URL", "/uploads"),
+		}
+	}
+}
+
+// GetStorage returns the configured storage, setting it up on first use
+func GetStorage() Storage {
+	if Store == nil {
+		InitStorage()
+	}
+	return Store
+}
+
+// CleanStorageKey normalizes a key and rejects ones leaving the root
+func CleanStorageKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.TrimSpace(key))[1:]
+	if cleaned == "" || strings.Contains(key, "..") || strings.ContainsRune(key, '\\') {
+		return "", ErrInvalidStorageKey
+	}
+	return cleaned, nil
+}
+
+// LocalStorage stores files in a directory on the local filesystem
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) Save(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial files
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return strings.TrimRight(s.BaseURL, "/") + "/" + key
+}
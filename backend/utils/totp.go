@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"shopping-cart/models"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 // seconds per time step
+	totpDigits = 6
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPURI builds the otpauth:// URI understood by authenticator apps
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPStep returns the RFC 6238 time step for t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TOTPCode computes the code for the given secret at time t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totpCodeAt(secret, TOTPStep(t))
+}
+
+func totpCodeAt(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as described in RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// ValidateTOTP checks a code against the current time step and one step on
+// either side to allow for clock drift. Steps at or before lastStep are
+// rejected so a code cannot be replayed. It returns the matched step.
+func ValidateTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - 1; step <= current+1; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCodeAt(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TwoFactorRequired reports whether the user must use two-factor
+// authentication, either because an admin required it for the account or
+// because REQUIRE_2FA_FOR_ADMINS applies to admin accounts
+func TwoFactorRequired(user models.User) bool {
+	if user.TwoFactorRequired {
+		return true
+	}
+	return user.Role == models.RoleAdmin && GetEnvBool("REQUIRE_2FA_FOR_ADMINS", false)
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"shopping-cart/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotifyWishlistChanges emails users whose wishlisted items dropped in
+// price or came back in stock since they were last told. Only users with
+// a verified email are notified, but every entry is brought up to date.
+// Entries whose email could not be sent are kept as they were, so the next
+// run tries again.
+func NotifyWishlistChanges(db *gorm.DB, now time.Time) error {
+	var wishlists []models.Wishlist
+	return db.Preload("Items.Item").Preload("Items.Variant").
+		FindInBatches(&wishlists, 100, func(tx *gorm.DB, batch int) error {
+			for _, wishlist := range wishlists {
+				if err := notifyWishlist(db, wishlist, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func notifyWishlist(db *gorm.DB, wishlist models.Wishlist, now time.Time) error {
+	var drops, restocks []string
+	var changed []models.WishlistItem
+	for _, entry := range wishlist.Items {
+		price := entry.CurrentPrice()
+		inStock := entry.Available(now)
+		if price == entry.LastPrice && inStock == entry.LastInStock {
+			continue
+		}
+
+		if inStock && !entry.LastInStock {
+			restocks = append(restocks, fmt.Sprintf("- %s is back in stock at %.2f", entry.Item.Name, price))
+		} else if inStock && price < entry.LastPrice {
+			drops = append(drops, fmt.Sprintf("- %s dropped from %.2f to %.2f", entry.Item.Name, entry.LastPrice, price))
+		}
+		entry.LastPrice = price
+		entry.LastInStock = inStock
+		changed = append(changed, entry)
+	}
+
+	if len(drops) > 0 || len(restocks) > 0 {
+		var user models.User
+		if err := db.First(&user, wishlist.UserID).Error; err == nil && user.Email != "" && user.EmailVerifiedAt != nil {
+			lines := append(drops, restocks...)
+			err := SendMail(MailMessage{
+				To:      user.Email,
+				Subject: "Good news about your wishlist",
+				Body: fmt.Sprintf("Hi %s,\n\nSome items on your wishlist have changed:\n\n%s\n\n%s/wishlist",
+					user.Username, strings.Join(lines, "\n"), GetEnv("FRONTEND_URL", "http://localhost:3000")),
+			})
+			if err != nil {
+				log.Printf("Failed to send wishlist alert to user %d: %v", user.ID, err)
+				return nil
+			}
+		}
+	}
+
+	for _, entry := range changed {
+		if err := db.Model(&models.WishlistItem{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+			"last_price":    entry.LastPrice,
+			"last_in_stock": entry.LastInStock,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}